@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+)
+
+type checkConfigCmd struct{}
+
+func (c *checkConfigCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := newPlex(ctx, cfg); err != nil {
+		return err
+	}
+
+	// validate pvrs can be initialised
+	for _, pvr := range cfg.Pvr.Radarr {
+		if _, _, err := newPvr(pvr.Name, cfg); err != nil {
+			return err
+		}
+	}
+
+	for _, pvr := range cfg.Pvr.Sonarr {
+		if _, _, err := newPvr(pvr.Name, cfg); err != nil {
+			return err
+		}
+	}
+
+	for _, pvr := range cfg.Pvr.Lidarr {
+		if _, _, err := newPvr(pvr.Name, cfg); err != nil {
+			return err
+		}
+	}
+
+	// validate jobs
+	for _, job := range cfg.Jobs {
+		if _, err := job.schedule(); err != nil {
+			return err
+		}
+	}
+
+	log.Info().
+		Str("config", cli.Config).
+		Int("radarr", len(cfg.Pvr.Radarr)).
+		Int("sonarr", len(cfg.Pvr.Sonarr)).
+		Int("lidarr", len(cfg.Pvr.Lidarr)).
+		Int("jobs", len(cfg.Jobs)).
+		Msg("Configuration is valid")
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"github.com/l3uddz/plexarr"
+	"github.com/l3uddz/plexarr/report"
+)
+
+// checkEpisodes reports episode files plex attached to a different season / episode than the pvr.
+func (r *runner) checkEpisodes(ctx context.Context, clients *pvrClients, matched []matchedItem) error {
+	checked, mismatched := 0, 0
+	for _, item := range matched {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if item.Library.Type != plexarr.TvLibrary {
+			continue
+		}
+
+		client, err := clients.get(item.PvrItem.Pvr)
+		if err != nil {
+			return err
+		}
+
+		pvr, ok := client.pvr.(plexarr.EpisodePvr)
+		if !ok {
+			continue
+		}
+
+		l := r.log.With().
+			Str("pvr", item.PvrItem.Pvr).
+			Str("plex_path", item.PlexItem.Path).
+			Logger()
+
+		// retrieve episodes
+		pvrFiles, err := pvr.GetEpisodeFiles(ctx, item.PvrItem)
+		if err != nil {
+			l.Warn().
+				Err(err).
+				Msg("Failed retrieving pvr episode files, skipping item")
+			continue
+		}
+
+		plexFiles, err := r.plex.GetEpisodeFiles(ctx, item.PlexItem)
+		if err != nil {
+			l.Warn().
+				Err(err).
+				Msg("Failed retrieving plex episode files, skipping item")
+			continue
+		}
+
+		normalizedFiles := make(map[string][]plexarr.Episode, len(plexFiles))
+		for path, episodes := range plexFiles {
+			normalizedFiles[clients.normalize(path)] = episodes
+		}
+
+		// compare episodes of every file
+		for _, file := range pvrFiles {
+			plexEpisodes, ok := normalizedFiles[clients.normalize(file.Path)]
+			if !ok {
+				l.Debug().
+					Str("file", file.Path).
+					Msg("Episode file not found in plex")
+				continue
+			}
+
+			checked++
+			if episodesMatched(plexEpisodes, file.Episodes) {
+				continue
+			}
+
+			mismatched++
+			e := report.Entry{
+				Category:     report.Episode,
+				Library:      item.Library.Name,
+				Location:     item.PlexItem.Location.Path,
+				MetadataId:   item.PlexItem.MetadataId,
+				PlexPath:     file.Path,
+				PlexGUID:     item.PlexItem.GUID,
+				Pvr:          item.PvrItem.Pvr,
+				Title:        item.PvrItem.Title,
+				PvrPath:      file.PvrPath,
+				PvrGUIDs:     item.PvrItem.GUID,
+				PlexEpisodes: episodeStrings(plexEpisodes),
+				PvrEpisodes:  episodeStrings(file.Episodes),
+			}
+
+			r.report.Add(e)
+			l.Warn().
+				Str("file", file.Path).
+				Strs("plex_episodes", e.PlexEpisodes).
+				Strs("pvr_episodes", e.PvrEpisodes).
+				Msg("Mismatched episode")
+		}
+	}
+
+	r.log.Info().
+		Int("checked", checked).
+		Int("mismatched", mismatched).
+		Msg("Checked episodes")
+	return nil
+}
+
+func episodesMatched(plexEpisodes []plexarr.Episode, pvrEpisodes []plexarr.Episode) bool {
+	if len(plexEpisodes) != len(pvrEpisodes) {
+		return false
+	}
+
+	episodes := make(map[plexarr.Episode]bool, len(plexEpisodes))
+	for _, e := range plexEpisodes {
+		episodes[e] = true
+	}
+
+	for _, e := range pvrEpisodes {
+		if !episodes[e] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func episodeStrings(episodes []plexarr.Episode) []string {
+	s := make([]string, 0, len(episodes))
+	for _, e := range episodes {
+		s = append(s, e.String())
+	}
+
+	return s
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// defaultMaxFailures is the number of consecutive failures a run is aborted after, unless configured.
+const defaultMaxFailures = 5
+
+type failureFlags struct {
+	ContinueOnError bool `name:"continue-on-error" help:"Continue with the remaining items when an item fails"`
+	MaxFailures     int  `name:"max-failures" default:"${max_failures}" help:"Abort after this many consecutive failures when continuing on error (0: never)"`
+}
+
+// itemFailure is an item that could not be split / matched.
+type itemFailure struct {
+	Operation string
+	Library   string
+	Path      string
+	Err       error
+}
+
+// failed records a failed item, it returns an error when the run must be aborted.
+func (r *runner) failed(operation string, library string, path string, err error) error {
+	if !r.failureFlags.ContinueOnError || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	r.failures = append(r.failures, itemFailure{
+		Operation: operation,
+		Library:   library,
+		Path:      path,
+		Err:       err,
+	})
+
+	r.consecutiveFailures++
+	if r.failureFlags.MaxFailures > 0 && r.consecutiveFailures >= r.failureFlags.MaxFailures {
+		r.log.Error().
+			Err(err).
+			Str("library", library).
+			Str("path", path).
+			Int("failures", r.consecutiveFailures).
+			Msgf("Failed to %s item, aborting", operation)
+		return fmt.Errorf("aborting after %d consecutive failures: %w", r.consecutiveFailures, err)
+	}
+
+	r.log.Error().
+		Err(err).
+		Str("library", library).
+		Str("path", path).
+		Msgf("Failed to %s item, continuing", operation)
+	return nil
+}
+
+// succeeded resets the consecutive failure count.
+func (r *runner) succeeded() {
+	r.consecutiveFailures = 0
+}
+
+// finish prints a summary of the failed items, returning an error when the run or any item failed.
+func (r *runner) finish(err error) error {
+	if len(r.failures) == 0 {
+		return err
+	}
+
+	r.printFailures(os.Stdout)
+	return r.failuresErr(err)
+}
+
+// finishLogged logs a summary of the failed items, e.g. for serve jobs, returning an error like finish.
+func (r *runner) finishLogged(l zerolog.Logger, err error) error {
+	for _, f := range r.failures {
+		l.Error().
+			Err(f.Err).
+			Str("operation", f.Operation).
+			Str("library", f.Library).
+			Str("path", f.Path).
+			Msg("Failed item")
+	}
+
+	return r.failuresErr(err)
+}
+
+func (r *runner) failuresErr(err error) error {
+	if err != nil || len(r.failures) == 0 {
+		return err
+	}
+
+	return fmt.Errorf("%d item(s) failed", len(r.failures))
+}
+
+func (r *runner) printFailures(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tLIBRARY\tPATH\tERROR")
+	for _, f := range r.failures {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\n", f.Operation, f.Library, f.Path, f.Err)
+	}
+
+	_ = w.Flush()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+)
+
+type fixCmd struct {
+	matchFlags
+	dryRunFlag
+	reportFlags
+	failureFlags
+	forceFlag
+}
+
+func (c *fixCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	j, err := openJournal(cli.Journal)
+	if err != nil {
+		return err
+	}
+
+	r := newRunner(p, j, c.DryRun)
+	r.episodes = c.Episodes
+	r.failureFlags = c.failureFlags
+	r.safety, r.force = cfg.Safety, c.Force
+	if err := r.startReport("fix", c.reportFlags, c.Library, c.PVR); err != nil {
+		return err
+	}
+	defer r.writeReport()
+
+	// get library items
+	plexItems, err := getPlexLibraryItems(ctx, p, c.Library)
+	if err != nil {
+		return fmt.Errorf("failed retrieving items from plex libraries: %w", err)
+	}
+
+	return r.finish(r.fixLibraries(ctx, newPvrClients(cfg), plexItems, c.PVR))
+}
+
+func (r *runner) fixLibraries(ctx context.Context, clients *pvrClients, plexItems []plexLibraryItem,
+	pvrs []string) error {
+	res, err := r.matchLibraries(ctx, clients, plexItems, pvrs)
+	if err != nil {
+		return err
+	}
+
+	// display files in pvr / plex that cannot be matched
+	defer res.logUnmatched(zerolog.DebugLevel)
+
+	if r.episodes {
+		if err := r.checkEpisodes(ctx, clients, res.Matched); err != nil {
+			return err
+		}
+	}
+
+	// proceed no further if no mismatches
+	if len(res.ItemsToFix) == 0 {
+		r.log.Info().Msg("No mismatched items found!")
+		return nil
+	}
+
+	if err := r.checkFixLimit(res.ItemsToFix, plexItems); err != nil {
+		return err
+	}
+
+	if _, err := r.fixItems(ctx, res.ItemsToFix); err != nil {
+		return err
+	}
+
+	r.log.Info().Msg("Finished!")
+	return nil
+}
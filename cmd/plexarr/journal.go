@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	operationSplit = "split"
+	operationMatch = "match"
+)
+
+// statusUnconfirmed marks changes whose request failed in transit, plex may or may not have applied them.
+const statusUnconfirmed = "unconfirmed"
+
+// journalEntry records a change made to plex along with the state needed to revert it.
+type journalEntry struct {
+	Run           string    `json:"run"`
+	Time          time.Time `json:"time"`
+	Operation     string    `json:"operation"`
+	Library       string    `json:"library"`
+	MetadataId    uint64    `json:"metadata_item_id"`
+	Path          string    `json:"path"`
+	PreviousGUID  string    `json:"previous_guid"`
+	PreviousTitle string    `json:"previous_title"`
+	GUID          string    `json:"guid,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	Status        string    `json:"status,omitempty"`
+}
+
+// journal is an append-only JSON lines file of every split and match.
+type journal struct {
+	path string
+	mu   sync.Mutex
+}
+
+func openJournal(path string) (*journal, error) {
+	// validate journal can be written
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening journal: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed closing journal: %w", err)
+	}
+
+	return &journal{path: path}, nil
+}
+
+func (j *journal) Record(entry journalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed encoding journal entry: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed opening journal: %w", err)
+	}
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed writing journal entry: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Entries returns the entries recorded by a run in the order they were made.
+func (j *journal) Entries(run string) ([]journalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening journal: %w", err)
+	}
+	defer f.Close()
+
+	entries := make([]journalEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed decoding journal entry: %w", err)
+		}
+
+		if entry.Run == run {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading journal: %w", err)
+	}
+
+	return entries, nil
+}
+
+func newRunID() string {
+	b := make([]byte, 2)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed generating run id")
+	}
+
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102-150405"), hex.EncodeToString(b))
+}
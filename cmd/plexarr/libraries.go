@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type librariesCmd struct{}
+
+func (c *librariesCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTYPE\tAGENT\tLOCATIONS")
+	for _, lib := range p.Libraries() {
+		paths := make([]string, 0, len(lib.Locations))
+		for _, loc := range lib.Locations {
+			paths = append(paths, loc.Path)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", lib.ID, lib.Name, lib.Type, lib.Agent, strings.Join(paths, ", "))
+	}
+
+	return w.Flush()
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/alecthomas/kong"
 	"github.com/l3uddz/plexarr"
 	"github.com/l3uddz/plexarr/plex"
+	"github.com/l3uddz/plexarr/pvrs/lidarr"
 	"github.com/l3uddz/plexarr/pvrs/radarr"
 	"github.com/l3uddz/plexarr/pvrs/sonarr"
 	"github.com/natefinch/lumberjack"
@@ -13,8 +16,10 @@ import (
 	"gopkg.in/yaml.v2"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"time"
+	"strconv"
+	"syscall"
 )
 
 type config struct {
@@ -24,7 +29,29 @@ type config struct {
 	Pvr struct {
 		Radarr []radarr.Config `yaml:"radarr"`
 		Sonarr []sonarr.Config `yaml:"sonarr"`
+		Lidarr []lidarr.Config `yaml:"lidarr"`
+
+		// number of pvr libraries retrieved concurrently
+		Concurrency int `yaml:"concurrency"`
 	} `yaml:"pvr"`
+
+	// Safety limits
+	Safety safetyConfig `yaml:"safety"`
+
+	// Path normalization before matching
+	Normalize []string `yaml:"normalize"`
+	normalize plexarr.Normalizer
+
+	// Fallback matching by title
+	TitleMatch titleMatchConfig `yaml:"title_match"`
+
+	// Preferred guid providers per library
+	GuidPreference map[string][]string `yaml:"guid_preference"`
+	guids          *guidStrategies
+
+	// Jobs (serve)
+	Jobs    []jobConfig   `yaml:"jobs"`
+	Webhook webhookConfig `yaml:"webhook"`
 }
 
 var (
@@ -38,14 +65,21 @@ var (
 		globals
 
 		// flags
-		PVR     []string `required:"1" type:"string" help:"PVR to match from"`
-		Library []string `required:"1" type:"string" help:"Plex Library to match against"`
-
 		Config    string `type:"path" default:"${config_file}" env:"PLEXARR_CONFIG" help:"Config file path"`
 		Log       string `type:"path" default:"${log_file}" env:"PLEXARR_LOG" help:"Log file path"`
+		Journal   string `type:"path" default:"${journal_file}" env:"PLEXARR_JOURNAL" help:"Journal file path"`
 		Verbosity int    `type:"counter" default:"0" short:"v" env:"PLEXARR_VERBOSITY" help:"Log level verbosity"`
 
-		DryRun bool `type:"bool" default:"0" env:"PLEXARR_DRY_RUN" help:"Dry run mode"`
+		// commands
+		Run         runCmd         `cmd:"" help:"Split duplicates and fix mismatched items"`
+		Fix         fixCmd         `cmd:"" help:"Fix mismatched items"`
+		Split       splitCmd       `cmd:"" help:"Split duplicate items"`
+		Report      reportCmd      `cmd:"" help:"Report mismatched and unmatched items"`
+		Libraries   librariesCmd   `cmd:"" help:"List plex libraries"`
+		CheckConfig checkConfigCmd `cmd:"" name:"check-config" help:"Validate configuration"`
+		TestRewrite testRewriteCmd `cmd:"" name:"test-rewrite" help:"Preview the path rewrites of a PVR"`
+		Serve       serveCmd       `cmd:"" help:"Run configured jobs on a schedule"`
+		Undo        undoCmd        `cmd:"" help:"Revert the matches made by a run"`
 	}
 )
 
@@ -54,20 +88,37 @@ type globals struct {
 	Update  updateFlag  `name:"update" help:"Update if newer version is available and quit"`
 }
 
+// matchFlags are shared by the commands that match plex library items against pvr items.
+type matchFlags struct {
+	PVR      []string `required:"1" type:"string" help:"PVR to match from"`
+	Library  []string `required:"1" type:"string" help:"Plex Library to match against"`
+	Episodes bool     `type:"bool" default:"0" help:"Check the episodes of matched series"`
+}
+
+type forceFlag struct {
+	Force bool `type:"bool" default:"0" help:"Ignore the safety limits"`
+}
+
+type dryRunFlag struct {
+	DryRun bool `type:"bool" default:"0" env:"PLEXARR_DRY_RUN" help:"Dry run mode"`
+}
+
 func main() {
 	// parse cli
 	ctx := kong.Parse(&cli,
 		kong.Name("plexarr"),
-		kong.Description("Fix mismatched media in Plex mastered by Sonarr/Radarr"),
+		kong.Description("Fix mismatched media in Plex mastered by Sonarr/Radarr/Lidarr"),
 		kong.UsageOnError(),
 		kong.ConfigureHelp(kong.HelpOptions{
 			Summary: true,
 			Compact: true,
 		}),
 		kong.Vars{
-			"version":     fmt.Sprintf("%s (%s@%s)", Version, GitCommit, Timestamp),
-			"config_file": filepath.Join(defaultConfigPath(), "config.yml"),
-			"log_file":    filepath.Join(defaultConfigPath(), "activity.log"),
+			"version":      fmt.Sprintf("%s (%s@%s)", Version, GitCommit, Timestamp),
+			"config_file":  filepath.Join(defaultConfigPath(), "config.yml"),
+			"log_file":     filepath.Join(defaultConfigPath(), "activity.log"),
+			"journal_file": filepath.Join(defaultConfigPath(), "journal.jsonl"),
+			"max_failures": strconv.Itoa(defaultMaxFailures),
 		},
 	)
 
@@ -98,233 +149,59 @@ func main() {
 		log.Logger = logger.Level(zerolog.InfoLevel)
 	}
 
-	// config
-	file, err := os.Open(cli.Config)
-	if err != nil {
+	// cancel on shutdown signal
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	// run command
+	ctx.BindTo(runCtx, (*context.Context)(nil))
+	if err := ctx.Run(); err != nil {
 		log.Fatal().
 			Err(err).
-			Msg("Failed opening config")
+			Str("command", ctx.Command()).
+			Msg("Failed running command")
+	}
+}
+
+func loadConfig(path string) (*config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening config: %w", err)
 	}
 	defer file.Close()
 
 	cfg := config{}
 	decoder := yaml.NewDecoder(file)
 	decoder.SetStrict(true)
-	err = decoder.Decode(&cfg)
-	if err != nil {
-		log.Fatal().
-			Err(err).
-			Msg("Failed decoding config")
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed decoding config: %w", err)
 	}
 
 	switch {
 	case cfg.Plex.URL == "":
-		log.Fatal().Msg("You must set a plex url in your configuration")
+		return nil, errors.New("you must set a plex url in your configuration")
 	case cfg.Plex.Token == "":
-		log.Fatal().Msg("You must set a plex token in your configuration")
-	case cfg.Plex.Database == "":
-		log.Fatal().Msg("You must set a plex database in your configuration")
-	}
-
-	// plex
-	p, err := plex.New(cfg.Plex)
-	if err != nil {
-		log.Fatal().
-			Err(err).
-			Msg("Failed initialising plex")
-	}
-
-	if err := p.Available(); err != nil {
-		log.Fatal().
-			Err(err).
-			Msg("Failed validating plex availability")
-	}
-
-	// get library items
-	l := log.With().
-		Strs("pvrs", cli.PVR).
-		Bool("dry_run", cli.DryRun).
-		Logger()
-
-	plexItems, err := getPlexLibraryItems(p, cli.Library)
-	if err != nil {
-		log.Fatal().
-			Err(err).
-			Msg("Failed retrieving items from plex libraries")
+		return nil, errors.New("you must set a plex token in your configuration")
+	case cfg.Pvr.Concurrency < 0:
+		return nil, errors.New("you must set a pvr concurrency of at-least 1 in your configuration")
 	}
 
-	// find and split duplicate items
-	l.Debug().Msg("Checking for duplicates...")
-
-	splitSize := 0
-	for _, lib := range plexItems {
-		split, err := splitDuplicates(p, lib)
-		if err != nil {
-			log.Fatal().
-				Err(err).
-				Msg("Failed finding and splitting duplicate plex library items")
-		}
-		splitSize += split
-	}
-
-	// refresh items (post-split)
-	if splitSize > 0 {
-		l.Info().
-			Int("count", splitSize).
-			Msg("Finished splitting all duplicate items")
-
-		time.Sleep(10 * time.Second)
-		l.Info().Msg("Refreshing plex library items...")
-
-		plexItems, err = getPlexLibraryItems(p, cli.Library)
-		if err != nil {
-			log.Fatal().
-				Err(err).
-				Msg("Failed retrieving items from plex libraries post-split")
-		}
-	} else {
-		l.Info().Msg("No duplicates found!")
-	}
-
-	// retrieve items from pvr
-	pvrItems, err := getPvrItems(cli.PVR, cfg, plexItems)
-	if err != nil {
-		log.Fatal().
-			Err(err).
-			Msg("Failed retrieving pvr library items")
-	}
-
-	if len(pvrItems) == 0 {
-		l.Fatal().Msg("No pvr library items retrieved?")
-	}
-
-	if len(cli.PVR) > 1 {
-		l.Info().
-			Int("count", len(pvrItems)).
-			Msg("Retrieved all pvr library items")
-	}
-
-	// track items not matched (display debug log)
-	plexItemsNotFound := make([]plex.MediaItem, 0)
-	pvrItemsNotFound := make(map[string]plexarr.PvrItem)
-	for k, v := range pvrItems {
-		pvrItemsNotFound[k] = v
-	}
-
-	// iterate plex items matching to pvr items
-	itemsToFix := make(map[plex.MediaItem]plexarr.PvrItem)
-
-	for _, plexLibrary := range plexItems {
-		for _, plexItem := range plexLibrary.Items {
-			// plex item found in pvr items?
-			pvrItem, ok := pvrItems[plexItem.Path]
-			if !ok {
-				// this plex item not found in pvr
-				plexItemsNotFound = append(plexItemsNotFound, plexItem)
-				continue
-			} else {
-				// plex item found in pvr
-				delete(pvrItemsNotFound, plexItem.Path)
-			}
-
-			// validate match against pvr item
-			plexGuids, err := getPlexGuids(plexItem.GUID)
-			if err != nil {
-				log.Fatal().
-					Err(err).
-					Interface("plex_item", plexItem).
-					Msg("Failed preparing plex guids for comparison")
-			}
-
-			if guidsMatched(plexGuids, pvrItem.GUID) {
-				l.Trace().
-					Interface("plex_item", plexItem).
-					Interface("pvr_item", pvrItem).
-					Msg("Match validated")
-				continue
-			}
-
-			// store item to fix
-			itemsToFix[plexItem] = pvrItem
-		}
-	}
-
-	// display files in pvr / plex that cannot be matched
-	defer func(missingPlexItems []plex.MediaItem, missingPvrItems map[string]plexarr.PvrItem) {
-		// show missing plex items
-		if len(missingPlexItems) > 0 {
-			for _, plexItem := range missingPlexItems {
-				log.Debug().
-					Interface("plex_item", plexItem).
-					Msg("Cannot match plex library item to pvr item...")
-			}
-		}
-
-		// show missing pvr items
-		if len(missingPvrItems) > 0 {
-			for _, pvrItem := range missingPvrItems {
-				log.Debug().
-					Interface("pvr_item", pvrItem).
-					Msg("Cannot match pvr item to plex library item...")
-			}
-		}
-
-	}(plexItemsNotFound, pvrItemsNotFound)
-
-	// proceed no further if no mismatches
-	if len(itemsToFix) == 0 {
-		log.Info().Msg("No mismatched items found!")
-		return
-	}
-
-	l.Info().
-		Int("count", len(itemsToFix)).
-		Msg("Mismatched found, fixing...")
-
-	// fix matches
-	fixedSize := 0
-	for plexItem, pvrItem := range itemsToFix {
-		newGuid := fmt.Sprintf("%s?lang=en", getPreferredGuid(pvrItem.GUID))
-
-		l.Debug().
-			Str("plex_path", plexItem.Path).
-			Str("plex_guid", plexItem.GUID).
-			Str("pvr_path", pvrItem.PvrPath).
-			Str("pvr_guid", newGuid).
-			Msgf("Fixing match to %v", newGuid)
-
-		if !cli.DryRun {
-			err = p.Match(int(plexItem.MetadataId), pvrItem.Title, newGuid)
-		} else {
-			err = nil
-		}
-
-		if err != nil {
-			log.Fatal().
-				Err(err).
-				Interface("plex_item", plexItem).
-				Interface("pvr_item", pvrItem).
-				Msg("Failed fixing match")
-		}
-
-		fixedSize++
-		log.Info().
-			Str("plex_path", plexItem.Path).
-			Str("plex_guid", plexItem.GUID).
-			Str("pvr_path", pvrItem.PvrPath).
-			Str("pvr_guid", newGuid).
-			Msg("Fixed match")
-
-		if !cli.DryRun {
-			time.Sleep(15 * time.Second)
+	if len(cfg.Normalize) > 0 {
+		if cfg.normalize, err = plexarr.NewNormalizer(cfg.Normalize); err != nil {
+			return nil, fmt.Errorf("failed initialising normalize: %w", err)
 		}
 	}
 
-	if fixedSize > 0 {
-		l.Info().
-			Int("count", fixedSize).
-			Msg("Finished fixing matches")
+	if cfg.guids, err = newGuidStrategies(&cfg); err != nil {
+		return nil, fmt.Errorf("failed initialising guid preference: %w", err)
 	}
 
-	log.Info().Msg("Finished!")
+	return &cfg, nil
 }
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"github.com/l3uddz/plexarr/plex"
+	"github.com/l3uddz/plexarr/report"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"sort"
+)
+
+// matchedItem pairs a plex library item with its pvr item, if any.
+type matchedItem struct {
+	Library  *plex.Library
+	PlexItem plex.MediaItem
+	PvrItem  plexarr.PvrItem
+
+	// normalized path both items were matched by
+	Path string
+	// confidence of items matched by title, 0 when matched by path
+	Confidence float64
+	// chooses the pvr guid to fix the match with
+	GuidStrategy plexarr.GuidStrategy
+}
+
+type matchResult struct {
+	Matched           []matchedItem
+	ItemsToFix        []matchedItem
+	PlexItemsNotFound []matchedItem
+	PvrItemsNotFound  map[string]plexarr.PvrItem
+	PvrItemsSkipped   []plexarr.PvrItem
+
+	// items paired by title, only reported unless title matches are fixed
+	TitleMatched []matchedItem
+}
+
+func matchLibraries(ctx context.Context, clients *pvrClients, plexItems []plexLibraryItem,
+	pvrs []string) (*matchResult, error) {
+	// retrieve items from pvr
+	pvrItems, err := getPvrItems(ctx, pvrs, clients, plexItems)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving pvr library items: %w", err)
+	}
+
+	if len(pvrItems.Items) == 0 {
+		return nil, errors.New("no pvr library items retrieved")
+	}
+
+	if len(pvrs) > 1 {
+		log.Info().
+			Int("count", len(pvrItems.Items)).
+			Msg("Retrieved all pvr library items")
+	}
+
+	res, err := matchItems(plexItems, pvrItems.Items, clients.normalize)
+	if err != nil {
+		return nil, err
+	}
+
+	// pair the remaining items by title
+	if err := matchTitles(res, clients.cfg.TitleMatch); err != nil {
+		return nil, err
+	}
+
+	for i, item := range res.ItemsToFix {
+		res.ItemsToFix[i].GuidStrategy = clients.guidStrategy(item.PvrItem.Pvr, item.Library.Name)
+	}
+
+	res.PvrItemsSkipped = append(pvrItems.Skipped, res.PvrItemsSkipped...)
+	return res, nil
+}
+
+func matchItems(plexItems []plexLibraryItem, pvrItems map[string]plexarr.PvrItem,
+	normalize plexarr.Normalizer) (*matchResult, error) {
+	// track items not matched
+	res := &matchResult{
+		Matched:           make([]matchedItem, 0),
+		ItemsToFix:        make([]matchedItem, 0),
+		PlexItemsNotFound: make([]matchedItem, 0),
+		PvrItemsNotFound:  make(map[string]plexarr.PvrItem),
+		PvrItemsSkipped:   make([]plexarr.PvrItem, 0),
+		TitleMatched:      make([]matchedItem, 0),
+	}
+
+	// pvr items by normalized path, paths that are no longer unique once normalized are skipped
+	paths := make([]string, 0, len(pvrItems))
+	for path := range pvrItems {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	normalizedItems := make(map[string]plexarr.PvrItem, len(pvrItems))
+	ambiguous := make(map[string]bool)
+	for _, path := range paths {
+		normalized := normalize(path)
+		if existing, ok := normalizedItems[normalized]; ok || ambiguous[normalized] {
+			if ok {
+				res.PvrItemsSkipped = append(res.PvrItemsSkipped, existing)
+				delete(normalizedItems, normalized)
+			}
+
+			log.Warn().
+				Str("path", path).
+				Str("normalized_path", normalized).
+				Msg("Skipping pvr item, normalized path is not unique")
+			res.PvrItemsSkipped = append(res.PvrItemsSkipped, pvrItems[path])
+			ambiguous[normalized] = true
+			continue
+		}
+
+		normalizedItems[normalized] = pvrItems[path]
+	}
+
+	for k, v := range normalizedItems {
+		res.PvrItemsNotFound[k] = v
+	}
+
+	// iterate plex items matching to pvr items
+	for _, plexLibrary := range plexItems {
+		for _, plexItem := range plexLibrary.Items {
+			// plex item found in pvr items?
+			path := normalize(plexItem.Path)
+			pvrItem, ok := normalizedItems[path]
+			if !ok {
+				// this plex item not found in pvr
+				res.PlexItemsNotFound = append(res.PlexItemsNotFound, matchedItem{
+					Library:  plexLibrary.Library,
+					PlexItem: plexItem,
+					Path:     path,
+				})
+				continue
+			} else {
+				// plex item found in pvr
+				delete(res.PvrItemsNotFound, path)
+			}
+
+			if err := res.add(matchedItem{
+				Library:  plexLibrary.Library,
+				PlexItem: plexItem,
+				PvrItem:  pvrItem,
+				Path:     path,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// add validates the match of a plex item against its pvr item, storing it as matched or to fix.
+func (r *matchResult) add(item matchedItem) error {
+	plexGuids, err := getPlexGuids(item.PlexItem)
+	if err != nil {
+		return fmt.Errorf("failed preparing plex guids for comparison: %v: %w", item.PlexItem.Path, err)
+	}
+
+	if guidsMatched(plexGuids, item.PvrItem.GUID) {
+		log.Trace().
+			Interface("plex_item", item.PlexItem).
+			Interface("pvr_item", item.PvrItem).
+			Msg("Match validated")
+
+		r.Matched = append(r.Matched, item)
+		return nil
+	}
+
+	// store item to fix
+	r.ItemsToFix = append(r.ItemsToFix, item)
+	return nil
+}
+
+// logUnmatched displays items in pvr / plex that cannot be matched.
+func (r *matchResult) logUnmatched(level zerolog.Level) {
+	// show missing plex items
+	for _, item := range r.PlexItemsNotFound {
+		log.WithLevel(level).
+			Str("library", item.Library.Name).
+			Interface("plex_item", item.PlexItem).
+			Msg("Cannot match plex library item to pvr item...")
+	}
+
+	// show missing pvr items
+	for _, pvrItem := range r.PvrItemsNotFound {
+		log.WithLevel(level).
+			Interface("pvr_item", pvrItem).
+			Msg("Cannot match pvr item to plex library item...")
+	}
+}
+
+func (r *runner) fixItems(ctx context.Context, itemsToFix []matchedItem) (int, error) {
+	l := r.log
+
+	l.Info().
+		Int("count", len(itemsToFix)).
+		Msg("Mismatched found, fixing...")
+
+	// items not processed remain pending
+	processed := 0
+	defer func() {
+		for _, item := range itemsToFix[processed:] {
+			r.report.Add(mismatchEntry(item, report.StatusPending, "", nil))
+		}
+	}()
+
+	// fix matches
+	fixedSize := 0
+	for _, item := range itemsToFix {
+		if err := ctx.Err(); err != nil {
+			return fixedSize, err
+		}
+
+		plexItem, pvrItem := item.PlexItem, item.PvrItem
+
+		newGuid, err := getMatchGuid(ctx, r.plex, item.Library, plexItem, pvrItem, item.GuidStrategy)
+		if err != nil && !errors.Is(err, plexarr.ErrUnmatchable) {
+			// e.g. plex is unavailable or may have matched an unrelated item
+			processed++
+			r.report.Add(mismatchEntry(item, report.StatusFailed, "", err))
+			if err := r.failed("match", item.Library.Name, plexItem.Path, err); err != nil {
+				return fixedSize, fmt.Errorf("failed fixing match for %v: %w", plexItem.Path, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			l.Warn().
+				Err(err).
+				Interface("plex_item", plexItem).
+				Interface("pvr_item", pvrItem).
+				Msg("Failed determining guid to match, skipping item")
+
+			processed++
+			r.report.Add(mismatchEntry(item, report.StatusSkipped, "", err))
+			continue
+		}
+
+		l.Debug().
+			Str("plex_path", plexItem.Path).
+			Str("plex_guid", plexItem.GUID).
+			Str("pvr_path", pvrItem.PvrPath).
+			Str("pvr_guid", newGuid).
+			Msgf("Fixing match to %v", newGuid)
+
+		if err := r.match(ctx, item.Library, plexItem, pvrItem.Title, newGuid); err != nil {
+			processed++
+			r.report.Add(mismatchEntry(item, report.StatusFailed, newGuid, err))
+			if err := r.failed("match", item.Library.Name, plexItem.Path, err); err != nil {
+				return fixedSize, fmt.Errorf("failed fixing match for %v: %w", plexItem.Path, err)
+			}
+			continue
+		}
+
+		r.succeeded()
+		processed++
+		if r.dryRun {
+			r.report.Add(mismatchEntry(item, report.StatusPending, newGuid, nil))
+		} else {
+			r.report.Add(mismatchEntry(item, report.StatusFixed, newGuid, nil))
+		}
+
+		fixedSize++
+		l.Info().
+			Str("plex_path", plexItem.Path).
+			Str("plex_guid", plexItem.GUID).
+			Str("pvr_path", pvrItem.PvrPath).
+			Str("pvr_guid", newGuid).
+			Msg("Fixed match")
+	}
+
+	if fixedSize > 0 {
+		l.Info().
+			Int("count", fixedSize).
+			Msg("Finished fixing matches")
+	}
+
+	return fixedSize, nil
+}
@@ -1,20 +1,45 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/l3uddz/plexarr"
 	"github.com/l3uddz/plexarr/plex"
 	"github.com/rs/zerolog/log"
-	"time"
 )
 
+func newPlex(ctx context.Context, cfg *config) (*plex.Client, error) {
+	p, err := plex.New(cfg.Plex)
+	if err != nil {
+		return nil, fmt.Errorf("failed initialising plex: %w", err)
+	}
+
+	if err := p.Available(ctx); err != nil {
+		return nil, fmt.Errorf("failed validating plex availability: %w", err)
+	}
+
+	if err := p.VerifyIdentity(ctx); err != nil {
+		return nil, fmt.Errorf("failed verifying plex server identity: %w", err)
+	}
+
+	return p, nil
+}
+
 type plexLibraryItem struct {
-	Name  string
-	Type  plexarr.LibraryType
-	Items []plex.MediaItem
+	Name    string
+	Type    plexarr.LibraryType
+	Library *plex.Library
+	Items   []plex.MediaItem
 }
 
-func getPlexLibraryItems(p *plex.Client, libraries []string) ([]plexLibraryItem, error) {
+func getPlexLibraryItems(ctx context.Context, p *plex.Client, libraries []string) ([]plexLibraryItem, error) {
+	// every library is read from the same view of the database
+	snapshot, err := p.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
 	plexItems := make([]plexLibraryItem, 0)
 	totalItems := 0
 
@@ -22,11 +47,9 @@ func getPlexLibraryItems(p *plex.Client, libraries []string) ([]plexLibraryItem,
 		// get library items
 		l := log.With().
 			Str("library", library).
-			Strs("pvrs", cli.PVR).
-			Bool("dry_run", cli.DryRun).
 			Logger()
 
-		items, libType, err := p.GetLibraryItems(library)
+		items, lib, err := snapshot.GetLibraryItems(ctx, library)
 		if err != nil {
 			return nil, fmt.Errorf("failed %q plex library items: %w", library, err)
 		}
@@ -42,10 +65,25 @@ func getPlexLibraryItems(p *plex.Client, libraries []string) ([]plexLibraryItem,
 			Int("count", count).
 			Msg("Retrieved plex library items")
 
+		// an empty location is most likely an unmounted disk
+		locations := make(map[int]int)
+		for _, item := range items {
+			locations[item.Location.ID]++
+		}
+
+		for _, loc := range lib.Locations {
+			if locations[loc.ID] == 0 {
+				l.Warn().
+					Str("location", loc.Path).
+					Msg("No plex library items found in library location")
+			}
+		}
+
 		plexItems = append(plexItems, plexLibraryItem{
-			Name:  library,
-			Type:  libType,
-			Items: items,
+			Name:    library,
+			Type:    lib.Type,
+			Library: lib,
+			Items:   items,
 		})
 	}
 
@@ -57,80 +95,28 @@ func getPlexLibraryItems(p *plex.Client, libraries []string) ([]plexLibraryItem,
 	return plexItems, nil
 }
 
-func findDuplicateItems(items []plex.MediaItem) ([]plex.MediaItem, error) {
-	// locate duplicate metadata_item ids
-	duplicateMetadataItemIds := make([]plex.MediaItem, 0)
-	uniqueMetadataItemIds := make(map[uint64]int)
-
-	for _, item := range items {
-		count, ok := uniqueMetadataItemIds[item.MetadataId]
-		if !ok {
-			// item does not exist
-			uniqueMetadataItemIds[item.MetadataId] = 1
-			continue
-		}
+func getMatchGuid(ctx context.Context, p *plex.Client, library *plex.Library, plexItem plex.MediaItem,
+	pvrItem plexarr.PvrItem, strategy plexarr.GuidStrategy) (string, error) {
+	guid := strategy(pvrItem.GUID)
+	if guid == "" {
+		return "", fmt.Errorf("pvr item has no guids: %w", plexarr.ErrUnmatchable)
+	}
 
-		// the item was a duplicate
-		if count == 1 {
-			duplicateMetadataItemIds = append(duplicateMetadataItemIds, item)
+	// legacy agents are matched directly
+	if !plex.UsesPlexAgent(library, plexItem) {
+		if library.Type == plexarr.MusicLibrary {
+			return "", fmt.Errorf("music libraries can only be matched with the plex music agent: %w",
+				plexarr.ErrUnmatchable)
 		}
 
-		uniqueMetadataItemIds[item.MetadataId]++
+		return fmt.Sprintf("%s?lang=en", guid), nil
 	}
 
-	return duplicateMetadataItemIds, nil
-}
-
-func splitDuplicates(p *plex.Client, library plexLibraryItem) (int, error) {
-	l := log.With().
-		Str("library", library.Name).
-		Strs("pvrs", cli.PVR).
-		Bool("dry_run", cli.DryRun).
-		Logger()
-
-	duplicates, err := findDuplicateItems(library.Items)
+	// plex agents require the plex guid of the pvr item
+	plexGuid, err := p.GetPlexGuid(ctx, int(plexItem.MetadataId), library.Type, guid, pvrItem.Title)
 	if err != nil {
-		return 0, fmt.Errorf("failed finding duplicates items in plex library %q: %w", library.Name, err)
-	}
-
-	duplicatesSize := len(duplicates)
-
-	// split duplicates
-	splitSize := 0
-	if len(duplicates) > 0 {
-		l.Debug().
-			Interface("duplicates", duplicates).
-			Int("count", duplicatesSize).
-			Msg("Duplicates found")
-		l.Warn().
-			Int("count", duplicatesSize).
-			Msg("Duplicates found, splitting...")
-
-		// iterate duplicates splitting
-		for _, duplicate := range duplicates {
-			if !cli.DryRun {
-				err = p.Split(int(duplicate.MetadataId))
-			} else {
-				err = nil
-			}
-
-			if err != nil {
-				return 0, fmt.Errorf("failed splitting duplicate item in plex library %q: %v: %w",
-					library.Name, duplicate, err)
-			}
-
-			splitSize++
-			l.Info().
-				Str("path", duplicate.Path).
-				Str("guid", duplicate.GUID).
-				Uint64("metadata_item_id", duplicate.MetadataId).
-				Msg("Split duplicate")
-
-			if !cli.DryRun {
-				time.Sleep(15 * time.Second)
-			}
-		}
+		return "", fmt.Errorf("failed resolving plex guid for %v: %w", guid, err)
 	}
 
-	return splitSize, nil
+	return plexGuid, nil
 }
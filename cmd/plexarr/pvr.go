@@ -1,36 +1,110 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/l3uddz/plexarr"
+	"github.com/l3uddz/plexarr/pvrs/lidarr"
 	"github.com/l3uddz/plexarr/pvrs/radarr"
 	"github.com/l3uddz/plexarr/pvrs/sonarr"
 	"github.com/rs/zerolog/log"
+	"sort"
 	"strings"
+	"sync"
 )
 
-func getPvr(name string, cfg config, libraries []plexLibraryItem) (plexarr.Pvr, error) {
+// pvrClients initialises pvr clients on first use so they can be reused across runs.
+type pvrClients struct {
+	cfg *config
+
+	mu      sync.Mutex
+	clients map[string]pvrClient
+}
+
+type pvrClient struct {
+	pvr         plexarr.Pvr
+	libraryType plexarr.LibraryType
+}
+
+func newPvrClients(cfg *config) *pvrClients {
+	return &pvrClients{
+		cfg:     cfg,
+		clients: make(map[string]pvrClient),
+	}
+}
+
+func (c *pvrClients) Get(name string, libraries []plexLibraryItem) (plexarr.Pvr, error) {
+	client, err := c.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate all libraries are supported by the pvr
+	for _, lib := range libraries {
+		if lib.Type != client.libraryType {
+			return nil, fmt.Errorf("%v only supports %v libraries", name, client.libraryType)
+		}
+	}
+
+	return client.pvr, nil
+}
+
+// normalize normalizes a plex or pvr path for comparison.
+func (c *pvrClients) normalize(path string) string {
+	if c.cfg.normalize == nil {
+		return path
+	}
+
+	return c.cfg.normalize(path)
+}
+
+// concurrency returns the number of pvr libraries retrieved concurrently.
+func (c *pvrClients) concurrency() int {
+	if c.cfg.Pvr.Concurrency == 0 {
+		return defaultPvrConcurrency
+	}
+
+	return c.cfg.Pvr.Concurrency
+}
+
+// guidStrategy returns the strategy choosing the guid items of a pvr in a library are matched with.
+func (c *pvrClients) guidStrategy(pvr string, library string) plexarr.GuidStrategy {
+	return c.cfg.guids.get(pvr, library)
+}
+
+func (c *pvrClients) get(name string) (*pvrClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, ok := c.clients[strings.ToLower(name)]
+	if !ok {
+		pvr, libType, err := newPvr(name, c.cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		client = pvrClient{pvr: pvr, libraryType: libType}
+		c.clients[strings.ToLower(name)] = client
+	}
+
+	return &client, nil
+}
+
+func newPvr(name string, cfg *config) (plexarr.Pvr, plexarr.LibraryType, error) {
 	// radarr
 	for _, pvr := range cfg.Pvr.Radarr {
 		if !strings.EqualFold(name, pvr.Name) {
 			continue
 		}
 
-		// validate all libraries are movies
-		for _, lib := range libraries {
-			if lib.Type != plexarr.MovieLibrary {
-				return nil, errors.New("radarr only supports movie libraries")
-			}
-		}
-
 		// init pvr object
 		p, err := radarr.New(pvr)
 		if err != nil {
-			return nil, fmt.Errorf("failed initialising radarr pvr %v: %w", pvr.Name, err)
+			return nil, 0, fmt.Errorf("failed initialising radarr pvr %v: %w", pvr.Name, err)
 		}
 
-		return p, nil
+		return p, plexarr.MovieLibrary, nil
 	}
 
 	// sonarr
@@ -39,44 +113,64 @@ func getPvr(name string, cfg config, libraries []plexLibraryItem) (plexarr.Pvr,
 			continue
 		}
 
-		// validate all libraries are series
-		for _, lib := range libraries {
-			if lib.Type != plexarr.TvLibrary {
-				return nil, errors.New("sonarr only supports tv libraries")
-			}
+		// init pvr object
+		p, err := sonarr.New(pvr)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed initialising sonarr pvr %v: %w", pvr.Name, err)
+		}
+
+		return p, plexarr.TvLibrary, nil
+	}
+
+	// lidarr
+	for _, pvr := range cfg.Pvr.Lidarr {
+		if !strings.EqualFold(name, pvr.Name) {
+			continue
 		}
 
 		// init pvr object
-		p, err := sonarr.New(pvr)
+		p, err := lidarr.New(pvr)
 		if err != nil {
-			return nil, fmt.Errorf("failed initialising sonarr pvr %v: %w", pvr.Name, err)
+			return nil, 0, fmt.Errorf("failed initialising lidarr pvr %v: %w", pvr.Name, err)
 		}
 
-		return p, nil
+		return p, plexarr.MusicLibrary, nil
 	}
 
-	return nil, errors.New("pvr not found")
+	return nil, 0, errors.New("pvr not found")
 }
 
-func getPvrItems(names []string, cfg config, plexItems []plexLibraryItem) (map[string]plexarr.PvrItem, error) {
-	pvrItems := make(map[string]plexarr.PvrItem)
+// defaultPvrConcurrency is the number of pvr libraries retrieved concurrently, unless configured.
+const defaultPvrConcurrency = 4
 
-	// iterate pvr names
-	for _, pvrName := range names {
-		// get pvr object
-		pvr, err := getPvr(pvrName, cfg, plexItems)
+func getPvrItems(ctx context.Context, names []string, clients *pvrClients,
+	plexItems []plexLibraryItem) (*plexarr.PvrLibrary, error) {
+	// get pvr objects
+	pvrs := make([]plexarr.Pvr, len(names))
+	for i, pvrName := range names {
+		pvr, err := clients.Get(pvrName, plexItems)
 		if err != nil {
 			return nil, fmt.Errorf("initialise pvr: %v: %w", pvrName, err)
 		}
 
-		// retrieve pvr items
-		items, err := pvr.GetLibraryItems()
-		if err != nil {
-			return nil, fmt.Errorf("retrieve pvr library items: %v: %w", pvrName, err)
-		} else if len(items) == 0 {
-			return nil, fmt.Errorf("retrieve pvr library items: %v: no items found", pvrName)
-		}
+		pvrs[i] = pvr
+	}
+
+	// retrieve pvr items concurrently
+	libraries, err := fetchPvrLibraries(ctx, names, pvrs, clients.concurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	// merge pvr items in the order the pvrs were given
+	pvrItems := make(map[string]plexarr.PvrItem)
+	skippedItems := make([]plexarr.PvrItem, 0)
+	collisions := make(map[string]bool)
+
+	for i, pvrName := range names {
+		library := libraries[i]
 
+		skippedItems = append(skippedItems, library.Skipped...)
 		itemsSkipped := 0
 		itemsAdded := 0
 
@@ -84,16 +178,37 @@ func getPvrItems(names []string, cfg config, plexItems []plexLibraryItem) (map[s
 			Str("pvr", pvrName).
 			Logger()
 
+		keys := make([]string, 0, len(library.Items))
+		for key := range library.Items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
 		// process pvr items
-		for key, item := range items {
+		for _, key := range keys {
+			item := library.Items[key]
+
+			// path already collided between pvrs?
+			if collisions[key] {
+				pl.Warn().
+					Interface("item", item).
+					Msg("Path is not unique to this pvr, skipping item(s)")
+
+				itemsSkipped++
+				skippedItems = append(skippedItems, item)
+				continue
+			}
+
 			// does key already exist in pvrItems (have we seen this path before?)
-			if _, exists := pvrItems[key]; exists {
+			if existing, exists := pvrItems[key]; exists {
 				// this key (path) already exists, ignore it
 				pl.Warn().
 					Interface("item", item).
 					Msg("Path is not unique to this pvr, skipping item(s)")
 
 				itemsSkipped++
+				skippedItems = append(skippedItems, existing, item)
+				collisions[key] = true
 				delete(pvrItems, key)
 				continue
 			}
@@ -108,5 +223,73 @@ func getPvrItems(names []string, cfg config, plexItems []plexLibraryItem) (map[s
 			Msg("Retrieved pvr library items")
 	}
 
-	return pvrItems, nil
+	return &plexarr.PvrLibrary{
+		Items:   pvrItems,
+		Skipped: skippedItems,
+	}, nil
+}
+
+// fetchPvrLibraries retrieves the library of every pvr, returning them in the order of the pvrs.
+func fetchPvrLibraries(ctx context.Context, names []string, pvrs []plexarr.Pvr,
+	concurrency int) ([]*plexarr.PvrLibrary, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	libraries := make([]*plexarr.PvrLibrary, len(pvrs))
+	errs := make([]error, len(pvrs))
+	sem := make(chan struct{}, concurrency)
+	wg := new(sync.WaitGroup)
+
+	for i := range pvrs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+
+			library, err := pvrs[i].GetLibraryItems(ctx)
+			switch {
+			case err != nil:
+				errs[i] = fmt.Errorf("retrieve pvr library items: %v: %w", names[i], err)
+				cancel()
+			case len(library.Items) == 0:
+				errs[i] = fmt.Errorf("retrieve pvr library items: %v: no items found", names[i])
+				cancel()
+			default:
+				libraries[i] = library
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// report the error of the first pvr that failed, rather than the cancellation of the others
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return libraries, nil
 }
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"github.com/l3uddz/plexarr/report"
+	"github.com/rs/zerolog"
+	"sort"
+	"strings"
+)
+
+type reportFlags struct {
+	ReportFile   string `name:"report" type:"path" help:"Write a report of the run to this file"`
+	ReportFormat string `name:"report-format" type:"string" help:"Report format: json, csv or markdown (default: file extension)"`
+}
+
+type reportCmd struct {
+	matchFlags
+	reportFlags
+}
+
+func (c *reportCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	// nothing is changed, so nothing is journaled
+	r := newRunner(p, nil, true)
+	r.episodes = c.Episodes
+	if err := r.startReport("report", c.reportFlags, c.Library, c.PVR); err != nil {
+		return err
+	}
+	defer r.writeReport()
+
+	// get library items
+	plexItems, err := getPlexLibraryItems(ctx, p, c.Library)
+	if err != nil {
+		return fmt.Errorf("failed retrieving items from plex libraries: %w", err)
+	}
+
+	clients := newPvrClients(cfg)
+	res, err := r.matchLibraries(ctx, clients, plexItems, c.PVR)
+	if err != nil {
+		return err
+	}
+
+	// display mismatches
+	for _, item := range res.ItemsToFix {
+		r.report.Add(mismatchEntry(item, report.StatusPending, "", nil))
+		r.log.Info().
+			Str("plex_path", item.PlexItem.Path).
+			Str("plex_guid", item.PlexItem.GUID).
+			Str("pvr_path", item.PvrItem.PvrPath).
+			Strs("pvr_guids", item.PvrItem.GUID).
+			Msg("Mismatched item")
+	}
+
+	// display files in pvr / plex that cannot be matched
+	res.logUnmatched(zerolog.InfoLevel)
+
+	if r.episodes {
+		if err := r.checkEpisodes(ctx, clients, res.Matched); err != nil {
+			return err
+		}
+	}
+
+	r.log.Info().
+		Int("matched", len(res.Matched)).
+		Int("mismatched", len(res.ItemsToFix)).
+		Int("title_matched", len(res.TitleMatched)).
+		Int("plex_not_found", len(res.PlexItemsNotFound)).
+		Int("pvr_not_found", len(res.PvrItemsNotFound)).
+		Int("pvr_skipped", len(res.PvrItemsSkipped)).
+		Msg("Finished!")
+	return nil
+}
+
+// startReport collects a report of the run when a report file was requested, refusing unsupported formats.
+func (r *runner) startReport(command string, f reportFlags, libraries []string, pvrs []string) error {
+	if f.ReportFile == "" {
+		return nil
+	}
+
+	if f.ReportFormat != "" && !supportedReportFormat(f.ReportFormat) {
+		return fmt.Errorf("unsupported report format %q: must be one of %v", f.ReportFormat,
+			strings.Join(report.Formats(), ", "))
+	}
+
+	r.report = report.New(r.id, command, r.dryRun, libraries, pvrs)
+	r.reportFlags = f
+	return nil
+}
+
+func supportedReportFormat(format string) bool {
+	for _, f := range report.Formats() {
+		if strings.EqualFold(f, format) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *runner) writeReport() {
+	if r.report == nil {
+		return
+	}
+
+	if err := r.report.WriteFile(r.reportFlags.ReportFile, r.reportFlags.ReportFormat); err != nil {
+		r.log.Error().
+			Err(err).
+			Str("report", r.reportFlags.ReportFile).
+			Msg("Failed writing report")
+		return
+	}
+
+	r.log.Info().
+		Str("report", r.reportFlags.ReportFile).
+		Msg("Written report")
+}
+
+// matchLibraries matches plex library items against pvr items, adding the outcome to the report.
+func (r *runner) matchLibraries(ctx context.Context, clients *pvrClients, plexItems []plexLibraryItem,
+	pvrs []string) (*matchResult, error) {
+	res, err := matchLibraries(ctx, clients, plexItems, pvrs)
+	if err != nil || r.report == nil {
+		return res, err
+	}
+
+	for _, item := range res.Matched {
+		r.report.Add(report.Entry{
+			Category:       report.Matched,
+			Library:        item.Library.Name,
+			Location:       item.PlexItem.Location.Path,
+			MetadataId:     item.PlexItem.MetadataId,
+			PlexPath:       item.PlexItem.Path,
+			NormalizedPath: normalizedPath(item.PlexItem.Path, item.Path),
+			PlexGUID:       item.PlexItem.GUID,
+			Pvr:            item.PvrItem.Pvr,
+			Confidence:     item.Confidence,
+			Title:          item.PvrItem.Title,
+			PvrPath:        item.PvrItem.PvrPath,
+			PvrGUIDs:       item.PvrItem.GUID,
+		})
+	}
+
+	for _, item := range res.TitleMatched {
+		r.report.Add(report.Entry{
+			Category:   report.TitleMatched,
+			Library:    item.Library.Name,
+			Location:   item.PlexItem.Location.Path,
+			MetadataId: item.PlexItem.MetadataId,
+			PlexPath:   item.PlexItem.Path,
+			PlexGUID:   item.PlexItem.GUID,
+			Pvr:        item.PvrItem.Pvr,
+			Title:      item.PvrItem.Title,
+			PvrPath:    item.PvrItem.PvrPath,
+			PvrGUIDs:   item.PvrItem.GUID,
+			Confidence: item.Confidence,
+		})
+	}
+
+	for _, item := range res.PlexItemsNotFound {
+		r.report.Add(report.Entry{
+			Category:       report.PlexNotFound,
+			Library:        item.Library.Name,
+			Location:       item.PlexItem.Location.Path,
+			MetadataId:     item.PlexItem.MetadataId,
+			PlexPath:       item.PlexItem.Path,
+			NormalizedPath: normalizedPath(item.PlexItem.Path, item.Path),
+			PlexGUID:       item.PlexItem.GUID,
+			Title:          item.PlexItem.Title,
+		})
+	}
+
+	paths := make([]string, 0, len(res.PvrItemsNotFound))
+	for path := range res.PvrItemsNotFound {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		r.report.Add(pvrEntry(report.PvrNotFound, res.PvrItemsNotFound[path], path, plexItems))
+	}
+
+	for _, item := range res.PvrItemsSkipped {
+		r.report.Add(pvrEntry(report.PathCollision, item, clients.normalize(item.Path), plexItems))
+	}
+
+	return res, nil
+}
+
+func mismatchEntry(item matchedItem, status report.Status, newGuid string, err error) report.Entry {
+	e := report.Entry{
+		Category:       report.Mismatched,
+		Status:         status,
+		Library:        item.Library.Name,
+		Location:       item.PlexItem.Location.Path,
+		MetadataId:     item.PlexItem.MetadataId,
+		PlexPath:       item.PlexItem.Path,
+		NormalizedPath: normalizedPath(item.PlexItem.Path, item.Path),
+		PlexGUID:       item.PlexItem.GUID,
+		Pvr:            item.PvrItem.Pvr,
+		Title:          item.PvrItem.Title,
+		PvrPath:        item.PvrItem.PvrPath,
+		PvrGUIDs:       item.PvrItem.GUID,
+		NewGUID:        newGuid,
+		Confidence:     item.Confidence,
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e
+}
+
+// pvrEntry reports a pvr item, with the plex library location its rewritten path belongs to.
+func pvrEntry(category report.Category, item plexarr.PvrItem, normalized string,
+	plexItems []plexLibraryItem) report.Entry {
+	location := ""
+	for _, lib := range plexItems {
+		if loc, ok := lib.Library.Location(item.Path); ok {
+			location = loc.Path
+			break
+		}
+	}
+
+	return report.Entry{
+		Category:       category,
+		Location:       location,
+		Pvr:            item.Pvr,
+		Title:          item.Title,
+		PlexPath:       item.Path,
+		NormalizedPath: normalizedPath(item.Path, normalized),
+		PvrPath:        item.PvrPath,
+		PvrGUIDs:       item.GUID,
+	}
+}
+
+// normalizedPath returns the normalized path, when normalizing changed it.
+func normalizedPath(raw string, normalized string) string {
+	if raw == normalized {
+		return ""
+	}
+
+	return normalized
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type testRewriteCmd struct {
+	PVR     string   `required:"1" type:"string" help:"PVR whose rewrite to test"`
+	Library []string `required:"1" type:"string" help:"Plex Library to look up rewritten paths in"`
+	Path    string   `type:"string" help:"PVR path to rewrite, by default the path of every PVR item is rewritten"`
+}
+
+func (c *testRewriteCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	plexItems, err := getPlexLibraryItems(ctx, p, c.Library)
+	if err != nil {
+		return fmt.Errorf("failed retrieving items from plex libraries: %w", err)
+	}
+
+	clients := newPvrClients(cfg)
+	paths := newPlexPaths(plexItems, clients.normalize)
+
+	// rewrite the sample path, or the paths of every pvr item
+	items := make([]plexarr.PvrItem, 0)
+	if c.Path != "" {
+		rewrite, err := pvrRewrite(c.PVR, cfg)
+		if err != nil {
+			return err
+		}
+
+		rewriter, err := plexarr.NewRewriter(rewrite)
+		if err != nil {
+			return fmt.Errorf("failed initialising rewrite of %v: %w", c.PVR, err)
+		}
+
+		items = append(items, plexarr.PvrItem{PvrPath: c.Path, Path: rewriter(c.Path)})
+	} else {
+		pvr, err := clients.Get(c.PVR, plexItems)
+		if err != nil {
+			return err
+		}
+
+		library, err := pvr.GetLibraryItems(ctx)
+		if err != nil {
+			return fmt.Errorf("failed retrieving pvr library items: %w", err)
+		}
+
+		for _, item := range library.Items {
+			items = append(items, item)
+		}
+		items = append(items, library.Skipped...)
+
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].PvrPath < items[j].PvrPath
+		})
+	}
+
+	// look up the rewritten paths
+	found := 0
+	suggestions := make(map[[2]string]int)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PVR PATH\tREWRITTEN PATH\tIN PLEX\tCLOSEST PLEX PATH")
+	for _, item := range items {
+		if paths.exists[clients.normalize(item.Path)] {
+			found++
+			fmt.Fprintf(w, "%s\t%s\tyes\t\n", item.PvrPath, item.Path)
+			continue
+		}
+
+		// comparing every path is only feasible for a single path
+		closest := paths.closest(item.Path, c.Path != "")
+		if closest != "" {
+			if from, to, ok := suggestPrefixRewrite(item.PvrPath, closest); ok {
+				suggestions[[2]string{from, to}]++
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\tno\t%s\n", item.PvrPath, item.Path, closest)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	log.Info().
+		Int("found", found).
+		Int("not_found", len(items)-found).
+		Msg("Tested rewrite")
+
+	for _, s := range sortedSuggestions(suggestions) {
+		log.Info().
+			Str("from", s[0]).
+			Str("to", s[1]).
+			Int("items", suggestions[s]).
+			Msg("Suggested prefix rewrite rule")
+	}
+
+	return nil
+}
+
+func pvrRewrite(name string, cfg *config) (plexarr.Rewrite, error) {
+	for _, pvr := range cfg.Pvr.Radarr {
+		if strings.EqualFold(name, pvr.Name) {
+			return pvr.Rewrite, nil
+		}
+	}
+
+	for _, pvr := range cfg.Pvr.Sonarr {
+		if strings.EqualFold(name, pvr.Name) {
+			return pvr.Rewrite, nil
+		}
+	}
+
+	for _, pvr := range cfg.Pvr.Lidarr {
+		if strings.EqualFold(name, pvr.Name) {
+			return pvr.Rewrite, nil
+		}
+	}
+
+	return plexarr.Rewrite{}, errors.New("pvr not found")
+}
+
+// plexPaths are the paths of plex library items, indexed by their normalized path and folder name.
+type plexPaths struct {
+	exists map[string]bool
+	byName map[string][]string
+	all    []string
+}
+
+func newPlexPaths(plexItems []plexLibraryItem, normalize plexarr.Normalizer) *plexPaths {
+	p := &plexPaths{
+		exists: make(map[string]bool),
+		byName: make(map[string][]string),
+		all:    make([]string, 0),
+	}
+
+	for _, lib := range plexItems {
+		for _, item := range lib.Items {
+			name := strings.ToLower(filepath.Base(item.Path))
+			p.exists[normalize(item.Path)] = true
+			p.byName[name] = append(p.byName[name], item.Path)
+			p.all = append(p.all, item.Path)
+		}
+	}
+
+	sort.Strings(p.all)
+	return p
+}
+
+// closest returns the plex path with the same folder name, or optionally the one with the smallest edit distance.
+func (p *plexPaths) closest(path string, distance bool) string {
+	if paths := p.byName[strings.ToLower(filepath.Base(path))]; len(paths) > 0 {
+		return closestPath(path, paths)
+	}
+
+	if !distance {
+		return ""
+	}
+
+	return closestPath(path, p.all)
+}
+
+func closestPath(path string, paths []string) string {
+	closest, closestDistance := "", -1
+	for _, candidate := range paths {
+		if d := levenshtein(path, candidate); closestDistance < 0 || d < closestDistance {
+			closest, closestDistance = candidate, d
+		}
+	}
+
+	return closest
+}
+
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev, cur := make([]int, len(rb)+1), make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
+
+// suggestPrefixRewrite returns the roots of a pvr and plex path sharing their trailing folders.
+func suggestPrefixRewrite(pvrPath string, plexPath string) (string, string, bool) {
+	pvrParts := strings.Split(filepath.ToSlash(pvrPath), "/")
+	plexParts := strings.Split(filepath.ToSlash(plexPath), "/")
+
+	shared := 0
+	for shared < len(pvrParts) && shared < len(plexParts) &&
+		pvrParts[len(pvrParts)-1-shared] == plexParts[len(plexParts)-1-shared] {
+		shared++
+	}
+
+	if shared == 0 || shared == len(pvrParts) || shared == len(plexParts) {
+		return "", "", false
+	}
+
+	from := strings.Join(pvrParts[:len(pvrParts)-shared], "/")
+	to := strings.Join(plexParts[:len(plexParts)-shared], "/")
+	return from, to, from != "" && to != ""
+}
+
+func sortedSuggestions(suggestions map[[2]string]int) [][2]string {
+	sorted := make([][2]string, 0, len(suggestions))
+	for s := range suggestions {
+		sorted = append(sorted, s)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if suggestions[sorted[i]] != suggestions[sorted[j]] {
+			return suggestions[sorted[i]] > suggestions[sorted[j]]
+		}
+		return sorted[i][0] < sorted[j][0]
+	})
+
+	return sorted
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"github.com/l3uddz/plexarr/plex"
+	"github.com/l3uddz/plexarr/report"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type runCmd struct {
+	matchFlags
+	dryRunFlag
+	reportFlags
+	failureFlags
+	forceFlag
+}
+
+func (c *runCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	j, err := openJournal(cli.Journal)
+	if err != nil {
+		return err
+	}
+
+	r := newRunner(p, j, c.DryRun)
+	r.episodes = c.Episodes
+	r.failureFlags = c.failureFlags
+	r.safety, r.force = cfg.Safety, c.Force
+	if err := r.startReport("run", c.reportFlags, c.Library, c.PVR); err != nil {
+		return err
+	}
+	defer r.writeReport()
+
+	return r.finish(r.run(ctx, newPvrClients(cfg), c.Library, c.PVR))
+}
+
+// runner applies the changes of a single run to plex, recording them in the journal.
+type runner struct {
+	id      string
+	plex    *plex.Client
+	journal *journal
+	dryRun  bool
+
+	// check the episodes of matched series
+	episodes bool
+
+	// items that failed when continuing on error
+	failureFlags        failureFlags
+	failures            []itemFailure
+	consecutiveFailures int
+
+	// refuse to change a suspicious amount of items
+	safety safetyConfig
+	force  bool
+
+	report      *report.Report
+	reportFlags reportFlags
+
+	log zerolog.Logger
+}
+
+func newRunner(p *plex.Client, j *journal, dryRun bool) *runner {
+	id := newRunID()
+
+	return &runner{
+		id:      id,
+		plex:    p,
+		journal: j,
+		dryRun:  dryRun,
+
+		log: log.With().
+			Str("run", id).
+			Bool("dry_run", dryRun).
+			Logger(),
+	}
+}
+
+// run runs every phase of the pipeline: split duplicates, then fix mismatched items.
+func (r *runner) run(ctx context.Context, clients *pvrClients, libraries []string, pvrs []string) error {
+	// get library items
+	plexItems, err := getPlexLibraryItems(ctx, r.plex, libraries)
+	if err != nil {
+		return fmt.Errorf("failed retrieving items from plex libraries: %w", err)
+	}
+
+	// find and split duplicate items
+	splitSize, err := r.splitLibraries(ctx, plexItems)
+	if err != nil {
+		return fmt.Errorf("failed finding and splitting duplicate plex library items: %w", err)
+	}
+
+	// refresh items (post-split)
+	if splitSize > 0 {
+		r.log.Info().Msg("Refreshing plex library items...")
+
+		plexItems, err = getPlexLibraryItems(ctx, r.plex, libraries)
+		if err != nil {
+			return fmt.Errorf("failed retrieving items from plex libraries post-split: %w", err)
+		}
+	}
+
+	return r.fixLibraries(ctx, clients, plexItems, pvrs)
+}
+
+func (r *runner) split(ctx context.Context, library *plex.Library, item plex.MediaItem) error {
+	if r.dryRun {
+		return nil
+	}
+
+	if err := r.plex.WaitLimit(ctx); err != nil {
+		return err
+	}
+
+	mediaItems, err := r.plex.CountMediaItems(ctx, item.MetadataId)
+	if err != nil {
+		return err
+	}
+
+	if err := r.record(journalEntry{
+		Run:           r.id,
+		Operation:     operationSplit,
+		Library:       library.Name,
+		MetadataId:    item.MetadataId,
+		Path:          item.Path,
+		PreviousGUID:  item.GUID,
+		PreviousTitle: item.Title,
+	}, r.plex.Split(ctx, int(item.MetadataId))); err != nil {
+		return err
+	}
+
+	return r.completed(r.plex.WaitForSplit(ctx, item.MetadataId, mediaItems), item.MetadataId)
+}
+
+func (r *runner) match(ctx context.Context, library *plex.Library, item plex.MediaItem, title string,
+	guid string) error {
+	if r.dryRun {
+		return nil
+	}
+
+	if err := r.plex.WaitLimit(ctx); err != nil {
+		return err
+	}
+
+	if err := r.record(journalEntry{
+		Run:           r.id,
+		Operation:     operationMatch,
+		Library:       library.Name,
+		MetadataId:    item.MetadataId,
+		Path:          item.Path,
+		PreviousGUID:  item.GUID,
+		PreviousTitle: item.Title,
+		GUID:          guid,
+		Title:         title,
+	}, r.plex.Match(ctx, int(item.MetadataId), title, guid)); err != nil {
+		return err
+	}
+
+	return r.completed(r.plex.WaitForMatch(ctx, item.MetadataId, guid), item.MetadataId)
+}
+
+// record journals the change made by a request, returning the request error.
+// Requests failing in transit or with an unavailable plex may still have been applied, so they are journaled as
+// unconfirmed for undo to check.
+func (r *runner) record(entry journalEntry, err error) error {
+	if err != nil && !errors.Is(err, plexarr.ErrUnconfirmedChange) && !errors.Is(err, plexarr.ErrPlexUnavailable) {
+		return err
+	}
+
+	if err != nil {
+		entry.Status = statusUnconfirmed
+	}
+
+	if jerr := r.journal.Record(entry); jerr != nil {
+		return jerr
+	}
+
+	return err
+}
+
+// completed tolerates changes plex has not completed in time, they are most likely still queued.
+func (r *runner) completed(err error, metadataItemId uint64) error {
+	if errors.Is(err, plexarr.ErrChangeTimeout) {
+		r.log.Warn().
+			Err(err).
+			Uint64("metadata_item_id", metadataItemId).
+			Msg("Plex has not completed the change yet, continuing")
+		return nil
+	}
+
+	return err
+}
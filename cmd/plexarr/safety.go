@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"github.com/l3uddz/plexarr/plex"
+	"strconv"
+	"strings"
+)
+
+// safetySample is the number of would-be changes shown when a limit is exceeded.
+const safetySample = 10
+
+type safetyConfig struct {
+	MaxFixes  *threshold `yaml:"max_fixes"`
+	MaxSplits *threshold `yaml:"max_splits"`
+}
+
+// defaultThreshold applies to unconfigured limits, it never refuses a handful of changes, e.g. in small libraries.
+var defaultThreshold = threshold{value: 25, percent: true, min: 10}
+
+func (c safetyConfig) maxFixes() threshold {
+	if c.MaxFixes == nil {
+		return defaultThreshold
+	}
+
+	return *c.MaxFixes
+}
+
+func (c safetyConfig) maxSplits() threshold {
+	if c.MaxSplits == nil {
+		return defaultThreshold
+	}
+
+	return *c.MaxSplits
+}
+
+// threshold is an absolute number of items, e.g. 100, or a percentage of library items, e.g. 10%.
+type threshold struct {
+	value   float64
+	percent bool
+
+	// number of items always allowed
+	min int
+}
+
+func (t *threshold) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	s := ""
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	s = strings.TrimSpace(s)
+	percent := strings.HasSuffix(s, "%")
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+	if err != nil || value < 0 {
+		return fmt.Errorf("invalid safety limit %q: must be a number of items or a percentage, e.g. 100 or 10%%", s)
+	}
+
+	t.value, t.percent = value, percent
+	return nil
+}
+
+func (t threshold) String() string {
+	if t.percent {
+		return fmt.Sprintf("%v%%", t.value)
+	}
+
+	return fmt.Sprintf("%v", t.value)
+}
+
+func (t threshold) exceeded(count int, total int) bool {
+	if count <= t.min {
+		return false
+	}
+
+	if t.percent {
+		return float64(count)*100 > t.value*float64(total)
+	}
+
+	return float64(count) > t.value
+}
+
+type limitExceededError struct {
+	operation string
+	library   string
+	count     int
+	total     int
+	limit     threshold
+}
+
+func (e *limitExceededError) Error() string {
+	return fmt.Sprintf("refusing to %s %d of %d items in library %q, above the safety limit of %v "+
+		"(use --force to override)", e.operation, e.count, e.total, e.library, e.limit)
+}
+
+// checkFixLimit refuses to fix a suspicious amount of items in any library.
+func (r *runner) checkFixLimit(itemsToFix []matchedItem, plexItems []plexLibraryItem) error {
+	for _, lib := range plexItems {
+		items := make([]matchedItem, 0)
+		for _, item := range itemsToFix {
+			if item.Library.ID == lib.Library.ID {
+				items = append(items, item)
+			}
+		}
+
+		limit := r.safety.maxFixes()
+		if !limit.exceeded(len(items), len(lib.Items)) {
+			continue
+		}
+
+		err := &limitExceededError{operation: "fix", library: lib.Name, count: len(items), total: len(lib.Items),
+			limit: limit}
+		if r.force {
+			r.log.Warn().
+				Err(err).
+				Msg("Safety limit exceeded, continuing as forced")
+			continue
+		}
+
+		// show a sample so the operator can spot e.g. a broken rewrite
+		for i, item := range items {
+			if i == safetySample {
+				break
+			}
+
+			r.log.Warn().
+				Str("library", lib.Name).
+				Str("plex_path", item.PlexItem.Path).
+				Str("plex_title", item.PlexItem.Title).
+				Str("plex_guid", item.PlexItem.GUID).
+				Str("pvr_path", item.PvrItem.PvrPath).
+				Str("pvr_title", item.PvrItem.Title).
+				Strs("pvr_guids", item.PvrItem.GUID).
+				Msg("Would fix match")
+		}
+
+		if r.dryRun {
+			r.log.Warn().
+				Err(err).
+				Msg("Safety limit exceeded")
+			continue
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// checkSplitLimit refuses to split a suspicious amount of items in a library.
+func (r *runner) checkSplitLimit(library plexLibraryItem, duplicates []plex.MediaItem) error {
+	limit := r.safety.maxSplits()
+	if !limit.exceeded(len(duplicates), len(library.Items)) {
+		return nil
+	}
+
+	err := &limitExceededError{operation: "split", library: library.Name, count: len(duplicates),
+		total: len(library.Items), limit: limit}
+	if r.force {
+		r.log.Warn().
+			Err(err).
+			Msg("Safety limit exceeded, continuing as forced")
+		return nil
+	}
+
+	for i, item := range duplicates {
+		if i == safetySample {
+			break
+		}
+
+		r.log.Warn().
+			Str("library", library.Name).
+			Str("path", item.Path).
+			Str("title", item.Title).
+			Str("guid", item.GUID).
+			Msg("Would split duplicate")
+	}
+
+	if r.dryRun {
+		r.log.Warn().
+			Err(err).
+			Msg("Safety limit exceeded")
+		return nil
+	}
+
+	return err
+}
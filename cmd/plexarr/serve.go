@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr/plex"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+type jobConfig struct {
+	Name     string        `yaml:"name"`
+	PVR      []string      `yaml:"pvr"`
+	Library  []string      `yaml:"library"`
+	Interval time.Duration `yaml:"interval"`
+	Cron     string        `yaml:"cron"`
+	DryRun   bool          `yaml:"dry_run"`
+	Episodes bool          `yaml:"episodes"`
+
+	ContinueOnError bool `yaml:"continue_on_error"`
+	MaxFailures     *int `yaml:"max_failures"`
+	Force           bool `yaml:"force"`
+}
+
+// maxFailures returns the consecutive failures the job is aborted after, defaulting like the cli.
+func (j jobConfig) maxFailures() int {
+	if j.MaxFailures == nil {
+		return defaultMaxFailures
+	}
+
+	return *j.MaxFailures
+}
+
+func (j jobConfig) schedule() (cron.Schedule, error) {
+	switch {
+	case j.Name == "":
+		return nil, errors.New("you must set a name for every job")
+	case len(j.PVR) == 0:
+		return nil, fmt.Errorf("you must set at-least one pvr for job: %v", j.Name)
+	case len(j.Library) == 0:
+		return nil, fmt.Errorf("you must set at-least one library for job: %v", j.Name)
+	case j.Interval != 0 && j.Cron != "":
+		return nil, fmt.Errorf("you must set either an interval or a cron expression for job: %v", j.Name)
+	case j.Interval > 0:
+		return cron.Every(j.Interval), nil
+	case j.Cron != "":
+		s, err := cron.ParseStandard(j.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression for job %v: %w", j.Name, err)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("you must set an interval or a cron expression for job: %v", j.Name)
+	}
+}
+
+type serveCmd struct{}
+
+func (c *serveCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Jobs) == 0 && cfg.Webhook.Listen == "" {
+		return errors.New("you must set at-least one job or a webhook listen address in your configuration")
+	}
+
+	// validate jobs
+	schedules := make([]cron.Schedule, 0, len(cfg.Jobs))
+	for _, job := range cfg.Jobs {
+		schedule, err := job.schedule()
+		if err != nil {
+			return err
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	// clients are shared by every job run
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	clients := newPvrClients(cfg)
+
+	j, err := openJournal(cli.Journal)
+	if err != nil {
+		return err
+	}
+
+	// schedule jobs
+	scheduler := cron.New(cron.WithChain(
+		cron.Recover(cronLogger{}),
+		cron.SkipIfStillRunning(cronLogger{}),
+	))
+
+	for i, job := range cfg.Jobs {
+		schedule := schedules[i]
+		scheduler.Schedule(schedule, newJob(ctx, p, j, clients, cfg.Safety, job))
+
+		log.Info().
+			Str("job", job.Name).
+			Strs("pvrs", job.PVR).
+			Strs("libraries", job.Library).
+			Time("next_run", schedule.Next(time.Now())).
+			Msg("Scheduled job")
+	}
+
+	scheduler.Start()
+
+	// serve webhooks
+	var webhooks *webhookServer
+	if cfg.Webhook.Listen != "" {
+		webhooks, err = newWebhookServer(ctx, cfg.Webhook, p, j, clients)
+		if err != nil {
+			<-scheduler.Stop().Done()
+			return err
+		}
+
+		if err := webhooks.ListenAndServe(); err != nil {
+			<-scheduler.Stop().Done()
+			return err
+		}
+	}
+
+	// wait for shutdown
+	<-ctx.Done()
+	log.Info().Msg("Shutting down, waiting for running jobs to finish...")
+
+	<-scheduler.Stop().Done()
+	if webhooks != nil {
+		webhooks.Wait()
+	}
+
+	log.Info().Msg("Finished!")
+	return nil
+}
+
+func newJob(ctx context.Context, p *plex.Client, j *journal, clients *pvrClients, safety safetyConfig,
+	job jobConfig) cron.FuncJob {
+	return func() {
+		r := newRunner(p, j, job.DryRun)
+		r.episodes = job.Episodes
+		r.failureFlags = failureFlags{
+			ContinueOnError: job.ContinueOnError,
+			MaxFailures:     job.maxFailures(),
+		}
+		r.safety, r.force = safety, job.Force
+		l := r.log.With().
+			Str("job", job.Name).
+			Logger()
+
+		l.Info().Msg("Running job")
+		start := time.Now()
+
+		err := r.finishLogged(l, r.run(ctx, clients, job.Library, job.PVR))
+		switch {
+		case errors.Is(err, context.Canceled):
+			l.Warn().Msg("Job cancelled")
+			return
+		case err != nil:
+			l.Error().
+				Err(err).
+				Msg("Failed running job")
+			return
+		}
+
+		l.Info().
+			Dur("duration", time.Since(start)).
+			Msg("Finished job")
+	}
+}
+
+// cronLogger adapts the global logger for use by the scheduler.
+type cronLogger struct{}
+
+func (cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	log.Debug().
+		Fields(cronFields(keysAndValues)).
+		Msg(msg)
+}
+
+func (cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	log.Error().
+		Err(err).
+		Fields(cronFields(keysAndValues)).
+		Msg(msg)
+}
+
+func cronFields(keysAndValues []interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+
+	return fields
+}
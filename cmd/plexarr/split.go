@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/l3uddz/plexarr/plex"
+	"github.com/l3uddz/plexarr/report"
+	"github.com/rs/zerolog/log"
+)
+
+func findDuplicateItems(items []plex.MediaItem) ([]plex.MediaItem, error) {
+	// locate duplicate metadata_item ids
+	duplicateMetadataItemIds := make([]plex.MediaItem, 0)
+	uniqueMetadataItemIds := make(map[uint64]int)
+
+	for _, item := range items {
+		count, ok := uniqueMetadataItemIds[item.MetadataId]
+		if !ok {
+			// item does not exist
+			uniqueMetadataItemIds[item.MetadataId] = 1
+			continue
+		}
+
+		// the item was a duplicate
+		if count == 1 {
+			duplicateMetadataItemIds = append(duplicateMetadataItemIds, item)
+		}
+
+		uniqueMetadataItemIds[item.MetadataId]++
+	}
+
+	return duplicateMetadataItemIds, nil
+}
+
+func (r *runner) splitDuplicates(ctx context.Context, library plexLibraryItem) (int, error) {
+	l := r.log.With().
+		Str("library", library.Name).
+		Logger()
+
+	duplicates, err := findDuplicateItems(library.Items)
+	if err != nil {
+		return 0, fmt.Errorf("failed finding duplicates items in plex library %q: %w", library.Name, err)
+	}
+
+	duplicatesSize := len(duplicates)
+	if err := r.checkSplitLimit(library, duplicates); err != nil {
+		return 0, err
+	}
+
+	// split duplicates
+	splitSize := 0
+	if len(duplicates) > 0 {
+		l.Debug().
+			Interface("duplicates", duplicates).
+			Int("count", duplicatesSize).
+			Msg("Duplicates found")
+		l.Warn().
+			Int("count", duplicatesSize).
+			Msg("Duplicates found, splitting...")
+
+		// iterate duplicates splitting
+		for _, duplicate := range duplicates {
+			if err := ctx.Err(); err != nil {
+				return splitSize, err
+			}
+
+			if err := r.split(ctx, library.Library, duplicate); err != nil {
+				r.report.Add(duplicateEntry(library, duplicate, report.StatusFailed, err))
+				if err := r.failed("split", library.Name, duplicate.Path, err); err != nil {
+					return splitSize, fmt.Errorf("failed splitting duplicate item in plex library %q: %v: %w",
+						library.Name, duplicate, err)
+				}
+				continue
+			}
+
+			r.succeeded()
+
+			if r.dryRun {
+				r.report.Add(duplicateEntry(library, duplicate, report.StatusPending, nil))
+			} else {
+				r.report.Add(duplicateEntry(library, duplicate, report.StatusSplit, nil))
+			}
+
+			splitSize++
+			l.Info().
+				Str("path", duplicate.Path).
+				Str("guid", duplicate.GUID).
+				Uint64("metadata_item_id", duplicate.MetadataId).
+				Msg("Split duplicate")
+		}
+	}
+
+	return splitSize, nil
+}
+
+func (r *runner) splitLibraries(ctx context.Context, plexItems []plexLibraryItem) (int, error) {
+	r.log.Debug().Msg("Checking for duplicates...")
+
+	splitSize := 0
+	for _, lib := range plexItems {
+		split, err := r.splitDuplicates(ctx, lib)
+		splitSize += split
+		if err != nil {
+			return splitSize, err
+		}
+	}
+
+	if splitSize > 0 {
+		r.log.Info().
+			Int("count", splitSize).
+			Msg("Finished splitting all duplicate items")
+	} else {
+		r.log.Info().Msg("No duplicates found!")
+	}
+
+	return splitSize, nil
+}
+
+type splitCmd struct {
+	dryRunFlag
+	reportFlags
+	failureFlags
+	forceFlag
+
+	Library []string `required:"1" type:"string" help:"Plex Library to split duplicates in"`
+}
+
+func (c *splitCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	j, err := openJournal(cli.Journal)
+	if err != nil {
+		return err
+	}
+
+	r := newRunner(p, j, c.DryRun)
+	r.failureFlags = c.failureFlags
+	r.safety, r.force = cfg.Safety, c.Force
+	if err := r.startReport("split", c.reportFlags, c.Library, nil); err != nil {
+		return err
+	}
+	defer r.writeReport()
+
+	plexItems, err := getPlexLibraryItems(ctx, p, c.Library)
+	if err != nil {
+		return fmt.Errorf("failed retrieving items from plex libraries: %w", err)
+	}
+
+	if _, err := r.splitLibraries(ctx, plexItems); err != nil {
+		return r.finish(fmt.Errorf("failed finding and splitting duplicate plex library items: %w", err))
+	}
+
+	log.Info().Msg("Finished!")
+	return r.finish(nil)
+}
+
+func duplicateEntry(library plexLibraryItem, item plex.MediaItem, status report.Status, err error) report.Entry {
+	e := report.Entry{
+		Category:   report.Duplicate,
+		Status:     status,
+		Library:    library.Name,
+		Location:   item.Location.Path,
+		MetadataId: item.MetadataId,
+		PlexPath:   item.Path,
+		PlexGUID:   item.GUID,
+		Title:      item.Title,
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// titleMatchConfig pairs plex and pvr items whose paths differ by their title and year.
+type titleMatchConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// validate and fix pairings like path matches, otherwise they are only reported
+	Fix bool `yaml:"fix"`
+
+	// minimum confidence of a pairing, between 0 and 1 (default 0.9)
+	MinConfidence float64 `yaml:"min_confidence"`
+	// maximum difference between the plex and pvr year
+	YearTolerance int `yaml:"year_tolerance"`
+}
+
+// ambiguityMargin is the difference in confidence within which pairings of the same item are ambiguous.
+const ambiguityMargin = 0.02
+
+type titlePairing struct {
+	plex       int
+	pvr        string
+	confidence float64
+}
+
+// matchTitles pairs the plex and pvr items not found by path, accepting pairings above the minimum confidence.
+// Pairings about as confident as another pairing of the plex or pvr item are ambiguous and skipped.
+// Both items must have a year, only pvr items within the year tolerance are compared.
+func matchTitles(res *matchResult, cfg titleMatchConfig) error {
+	if !cfg.Enabled || len(res.PlexItemsNotFound) == 0 || len(res.PvrItemsNotFound) == 0 {
+		return nil
+	}
+
+	minConfidence := cfg.MinConfidence
+	if minConfidence <= 0 {
+		minConfidence = 0.9
+	}
+
+	yearTolerance := cfg.YearTolerance
+	if yearTolerance < 0 {
+		yearTolerance = 0
+	}
+
+	// pvr items by year
+	pvrTitles := make(map[string]string, len(res.PvrItemsNotFound))
+	pvrYears := make(map[int][]string)
+	for path, item := range res.PvrItemsNotFound {
+		if item.Year == 0 {
+			continue
+		}
+
+		pvrTitles[path] = normalizeTitle(item.Title)
+		pvrYears[item.Year] = append(pvrYears[item.Year], path)
+	}
+
+	// every pairing above the minimum confidence
+	pairings := make([]titlePairing, 0)
+	for i, plexItem := range res.PlexItemsNotFound {
+		plexTitle := normalizeTitle(plexItem.PlexItem.Title)
+		if plexTitle == "" || plexItem.PlexItem.Year == 0 {
+			continue
+		}
+
+		for year := plexItem.PlexItem.Year - yearTolerance; year <= plexItem.PlexItem.Year+yearTolerance; year++ {
+			for _, path := range pvrYears[year] {
+				confidence := titleConfidence(plexTitle, plexItem.PlexItem.Year, pvrTitles[path], year,
+					yearTolerance)
+				if confidence >= minConfidence {
+					pairings = append(pairings, titlePairing{plex: i, pvr: path, confidence: confidence})
+				}
+			}
+		}
+	}
+
+	sort.Slice(pairings, func(i, j int) bool {
+		if pairings[i].confidence != pairings[j].confidence {
+			return pairings[i].confidence > pairings[j].confidence
+		}
+		if pairings[i].plex != pairings[j].plex {
+			return pairings[i].plex < pairings[j].plex
+		}
+		return pairings[i].pvr < pairings[j].pvr
+	})
+
+	// best pairings first
+	pairedPlex := make(map[int]bool)
+	pairedPvr := make(map[string]bool)
+	for i, p := range pairings {
+		if pairedPlex[p.plex] || pairedPvr[p.pvr] {
+			continue
+		}
+
+		plexItem := res.PlexItemsNotFound[p.plex]
+		if ambiguousPairing(pairings, i) {
+			log.Debug().
+				Str("plex_path", plexItem.PlexItem.Path).
+				Str("pvr_path", res.PvrItemsNotFound[p.pvr].PvrPath).
+				Float64("confidence", p.confidence).
+				Msg("Ambiguous title match, skipping item")
+			continue
+		}
+
+		pairedPlex[p.plex], pairedPvr[p.pvr] = true, true
+
+		plexItem.PvrItem = res.PvrItemsNotFound[p.pvr]
+		plexItem.Confidence = p.confidence
+		if !cfg.Fix {
+			res.TitleMatched = append(res.TitleMatched, plexItem)
+		} else if err := res.add(plexItem); err != nil {
+			return err
+		}
+
+		log.Debug().
+			Str("plex_path", plexItem.PlexItem.Path).
+			Str("pvr_path", plexItem.PvrItem.PvrPath).
+			Float64("confidence", p.confidence).
+			Bool("fix", cfg.Fix).
+			Msg("Paired item by title")
+	}
+
+	// remove paired items from the items not found
+	notFound := make([]matchedItem, 0, len(res.PlexItemsNotFound)-len(pairedPlex))
+	for i, item := range res.PlexItemsNotFound {
+		if !pairedPlex[i] {
+			notFound = append(notFound, item)
+		}
+	}
+	res.PlexItemsNotFound = notFound
+
+	for path := range pairedPvr {
+		delete(res.PvrItemsNotFound, path)
+	}
+
+	return nil
+}
+
+// ambiguousPairing reports whether another pairing of the plex or pvr item is within the ambiguity margin.
+func ambiguousPairing(pairings []titlePairing, i int) bool {
+	for j := i + 1; j < len(pairings) && pairings[i].confidence-pairings[j].confidence < ambiguityMargin; j++ {
+		if pairings[j].plex == pairings[i].plex || pairings[j].pvr == pairings[i].pvr {
+			return true
+		}
+	}
+
+	for j := i - 1; j >= 0 && pairings[j].confidence-pairings[i].confidence < ambiguityMargin; j-- {
+		if pairings[j].plex == pairings[i].plex || pairings[j].pvr == pairings[i].pvr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// titleConfidence returns the similarity of two normalized titles, 0 when either year is unknown or they differ.
+func titleConfidence(plexTitle string, plexYear int, pvrTitle string, pvrYear int, yearTolerance int) float64 {
+	if plexYear == 0 || pvrYear == 0 || plexYear-pvrYear > yearTolerance || pvrYear-plexYear > yearTolerance {
+		return 0
+	}
+
+	length := len([]rune(plexTitle))
+	if l := len([]rune(pvrTitle)); l > length {
+		length = l
+	}
+
+	if length == 0 {
+		return 0
+	}
+
+	return 1 - float64(levenshtein(plexTitle, pvrTitle))/float64(length)
+}
+
+// normalizeTitle lower cases a title, removing accents, punctuation and a leading article.
+func normalizeTitle(title string) string {
+	b := new(strings.Builder)
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// accents
+		case r == '&':
+			b.WriteString(" and ")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+
+	return strings.Join(words, " ")
+}
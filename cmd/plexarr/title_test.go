@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTitleConfidence(t *testing.T) {
+	tests := []struct {
+		name          string
+		plexTitle     string
+		plexYear      int
+		pvrTitle      string
+		pvrYear       int
+		yearTolerance int
+		want          float64
+	}{
+		{name: "identical", plexTitle: "Alien", plexYear: 1979, pvrTitle: "Alien", pvrYear: 1979, want: 1},
+		{name: "case and punctuation", plexTitle: "Se7en!", plexYear: 1995, pvrTitle: "se7en", pvrYear: 1995, want: 1},
+		{name: "accents", plexTitle: "Amélie", plexYear: 2001, pvrTitle: "Amelie", pvrYear: 2001, want: 1},
+		{name: "leading article", plexTitle: "The Thing", plexYear: 1982, pvrTitle: "Thing", pvrYear: 1982, want: 1},
+		{name: "ampersand", plexTitle: "Fast & Furious", plexYear: 2009, pvrTitle: "Fast and Furious", pvrYear: 2009,
+			want: 1},
+		{name: "one edit", plexTitle: "Alien", plexYear: 1986, pvrTitle: "Aliens", pvrYear: 1986, want: 1 - 1.0/6},
+		{name: "longer title", plexTitle: "Heat", plexYear: 1995, pvrTitle: "Heatwave", pvrYear: 1995, want: 0.5},
+		{name: "unrelated", plexTitle: "Heat", plexYear: 1995, pvrTitle: "Alien", pvrYear: 1995, want: 0},
+		{name: "year within tolerance", plexTitle: "Alien", plexYear: 1979, pvrTitle: "Alien", pvrYear: 1980,
+			yearTolerance: 1, want: 1},
+		{name: "year outside tolerance", plexTitle: "Alien", plexYear: 1979, pvrTitle: "Alien", pvrYear: 1980},
+		{name: "unknown plex year", plexTitle: "Alien", pvrTitle: "Alien", pvrYear: 1979, yearTolerance: 1},
+		{name: "unknown pvr year", plexTitle: "Alien", plexYear: 1979, pvrTitle: "Alien", yearTolerance: 1},
+		{name: "empty titles", plexTitle: "!", plexYear: 1979, pvrTitle: "", pvrYear: 1979},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := titleConfidence(normalizeTitle(tt.plexTitle), tt.plexYear, normalizeTitle(tt.pvrTitle), tt.pvrYear,
+				tt.yearTolerance)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("titleConfidence(%q, %v, %q, %v) = %v, want %v", tt.plexTitle, tt.plexYear, tt.pvrTitle,
+					tt.pvrYear, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type undoCmd struct {
+	dryRunFlag
+
+	RunID string `required:"1" name:"run" type:"string" help:"Run to undo"`
+}
+
+func (c *undoCmd) Run(ctx context.Context) error {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return err
+	}
+
+	j, err := openJournal(cli.Journal)
+	if err != nil {
+		return err
+	}
+
+	entries, err := j.Entries(c.RunID)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("no journal entries found for run: %v", c.RunID)
+	}
+
+	p, err := newPlex(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	r := newRunner(p, j, c.DryRun)
+	r.log.Info().
+		Str("undo_run", c.RunID).
+		Int("count", len(entries)).
+		Msg("Undoing run...")
+
+	// revert changes, most recent first
+	undoneSize := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry := entries[i]
+		l := r.log.With().
+			Str("library", entry.Library).
+			Str("path", entry.Path).
+			Uint64("metadata_item_id", entry.MetadataId).
+			Logger()
+
+		if entry.Operation != operationMatch {
+			l.Warn().
+				Str("operation", entry.Operation).
+				Msg("Operation cannot be undone, skipping")
+			continue
+		}
+
+		// unconfirmed matches are only undone when plex applied them
+		if entry.Status == statusUnconfirmed {
+			guid, err := r.plex.GetGuid(ctx, entry.MetadataId)
+			if err != nil {
+				return fmt.Errorf("failed undoing match for %v: %w", entry.Path, err)
+			}
+
+			if guid != entry.GUID {
+				l.Info().
+					Str("guid", guid).
+					Msg("Unconfirmed match was not applied, skipping")
+				continue
+			}
+		}
+
+		if err := r.restore(ctx, entry); err != nil {
+			return fmt.Errorf("failed undoing match for %v: %w", entry.Path, err)
+		}
+
+		undoneSize++
+		l.Info().
+			Str("guid", entry.GUID).
+			Str("previous_guid", entry.PreviousGUID).
+			Msg("Undone match")
+	}
+
+	r.log.Info().
+		Int("count", undoneSize).
+		Msg("Finished!")
+	return nil
+}
+
+// restore matches an item back to the guid recorded by the journal entry.
+func (r *runner) restore(ctx context.Context, entry journalEntry) error {
+	if r.dryRun {
+		return nil
+	}
+
+	if err := r.plex.WaitLimit(ctx); err != nil {
+		return err
+	}
+
+	guid, err := r.plex.GetGuid(ctx, entry.MetadataId)
+	if err != nil {
+		return err
+	}
+
+	// items previously unmatched are restored by unmatching them
+	unmatch := strings.HasPrefix(entry.PreviousGUID, "local://") || strings.HasPrefix(entry.PreviousGUID,
+		"com.plexapp.agents.none://")
+	if unmatch {
+		err = r.plex.Unmatch(ctx, int(entry.MetadataId))
+	} else {
+		err = r.plex.Match(ctx, int(entry.MetadataId), entry.PreviousTitle, entry.PreviousGUID)
+	}
+
+	if err := r.record(journalEntry{
+		Run:           r.id,
+		Operation:     operationMatch,
+		Library:       entry.Library,
+		MetadataId:    entry.MetadataId,
+		Path:          entry.Path,
+		PreviousGUID:  entry.GUID,
+		PreviousTitle: entry.Title,
+		GUID:          entry.PreviousGUID,
+		Title:         entry.PreviousTitle,
+	}, err); err != nil {
+		return err
+	}
+
+	// unmatched items get a new local guid
+	if unmatch {
+		return r.completed(r.plex.WaitForUnmatch(ctx, entry.MetadataId, guid), entry.MetadataId)
+	}
+
+	return r.completed(r.plex.WaitForMatch(ctx, entry.MetadataId, entry.PreviousGUID), entry.MetadataId)
+}
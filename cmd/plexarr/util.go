@@ -2,19 +2,75 @@ package main
 
 import (
 	"fmt"
+	"github.com/l3uddz/plexarr"
+	"github.com/l3uddz/plexarr/plex"
 	"strings"
 )
 
-func getPreferredGuid(guids []string) string {
-	for _, guid := range guids {
-		if strings.Contains(guid, "tvdb") {
-			return guid
-		} else if strings.Contains(guid, "imdb") {
-			return guid
+// guidStrategies are the guid strategies of the pvrs and libraries with a guid preference, by lower cased name.
+type guidStrategies struct {
+	pvrs      map[string]plexarr.GuidStrategy
+	libraries map[string]plexarr.GuidStrategy
+	fallback  plexarr.GuidStrategy
+}
+
+func newGuidStrategies(cfg *config) (*guidStrategies, error) {
+	fallback, err := plexarr.NewGuidStrategy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &guidStrategies{
+		pvrs:      make(map[string]plexarr.GuidStrategy),
+		libraries: make(map[string]plexarr.GuidStrategy),
+		fallback:  fallback,
+	}
+
+	pvrs := make(map[string][]string)
+	for _, pvr := range cfg.Pvr.Radarr {
+		pvrs[pvr.Name] = pvr.GuidPreference
+	}
+	for _, pvr := range cfg.Pvr.Sonarr {
+		pvrs[pvr.Name] = pvr.GuidPreference
+	}
+	for _, pvr := range cfg.Pvr.Lidarr {
+		pvrs[pvr.Name] = pvr.GuidPreference
+	}
+
+	for name, providers := range pvrs {
+		if len(providers) == 0 {
+			continue
 		}
+
+		strategy, err := plexarr.NewGuidStrategy(providers)
+		if err != nil {
+			return nil, fmt.Errorf("pvr %v: %w", name, err)
+		}
+		s.pvrs[strings.ToLower(name)] = strategy
+	}
+
+	for name, providers := range cfg.GuidPreference {
+		strategy, err := plexarr.NewGuidStrategy(providers)
+		if err != nil {
+			return nil, fmt.Errorf("library %v: %w", name, err)
+		}
+		s.libraries[strings.ToLower(name)] = strategy
+	}
+
+	return s, nil
+}
+
+// get returns the strategy of the library, otherwise of the pvr, otherwise the default strategy.
+func (s *guidStrategies) get(pvr string, library string) plexarr.GuidStrategy {
+	if strategy, ok := s.libraries[strings.ToLower(library)]; ok {
+		return strategy
+	}
+
+	if strategy, ok := s.pvrs[strings.ToLower(pvr)]; ok {
+		return strategy
 	}
 
-	return guids[0]
+	return s.fallback
 }
 
 func guidsMatched(plexGuids []string, pvrGuids []string) bool {
@@ -28,15 +84,20 @@ func guidsMatched(plexGuids []string, pvrGuids []string) bool {
 	return false
 }
 
-func getPlexGuids(externalGuids string) ([]string, error) {
-	guids := strings.Split(externalGuids, ",")
+func getPlexGuids(item plex.MediaItem) ([]string, error) {
+	// plex agent items are compared using their external guids
+	guids := []string{item.GUID}
+	if strings.HasPrefix(item.GUID, "plex://") && len(item.ExternalGUIDs) > 0 {
+		guids = item.ExternalGUIDs
+	}
+
 	formattedGuids := make([]string, 0)
 
 	for _, guid := range guids {
 		if strings.HasPrefix(guid, "tvdb://") {
 			formattedGuids = append(formattedGuids, fmt.Sprintf("com.plexapp.agents.the%s", guid))
 			continue
-		} else if strings.HasPrefix(guid, "com.plexapp.agents") {
+		} else if strings.HasPrefix(guid, "com.plexapp.agents") || strings.HasPrefix(guid, "plex://") {
 			formattedGuids = append(formattedGuids, guid)
 			continue
 		}
@@ -45,7 +106,7 @@ func getPlexGuids(externalGuids string) ([]string, error) {
 	}
 
 	if len(formattedGuids) == 0 {
-		return nil, fmt.Errorf("unable to format guids: %v", externalGuids)
+		return nil, fmt.Errorf("unable to format guids: %v", guids)
 	}
 
 	return formattedGuids, nil
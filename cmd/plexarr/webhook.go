@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"github.com/l3uddz/plexarr/plex"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type webhookConfig struct {
+	Listen  string        `yaml:"listen"`
+	Secret  string        `yaml:"secret"`
+	Delay   time.Duration `yaml:"delay"`
+	Retries int           `yaml:"retries"`
+	DryRun  bool          `yaml:"dry_run"`
+}
+
+type webhookServer struct {
+	cfg     webhookConfig
+	plex    *plex.Client
+	journal *journal
+	clients *pvrClients
+	ctx     context.Context
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+	checks  sync.WaitGroup
+	checkMu sync.Mutex
+}
+
+func newWebhookServer(ctx context.Context, cfg webhookConfig, p *plex.Client, j *journal,
+	clients *pvrClients) (*webhookServer, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("you must set a webhook secret in your configuration")
+	}
+
+	if cfg.Delay <= 0 {
+		cfg.Delay = time.Minute
+	}
+
+	if cfg.Retries <= 0 {
+		cfg.Retries = 5
+	}
+
+	return &webhookServer{
+		cfg:     cfg,
+		plex:    p,
+		journal: j,
+		clients: clients,
+		ctx:     ctx,
+		pending: make(map[string]*time.Timer),
+	}, nil
+}
+
+// ListenAndServe serves webhooks until the context is cancelled.
+func (s *webhookServer) ListenAndServe() error {
+	srv := &http.Server{
+		Addr:    s.cfg.Listen,
+		Handler: s,
+	}
+
+	go func() {
+		<-s.ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Failed shutting down webhook server")
+		}
+	}()
+
+	log.Info().
+		Str("listen", s.cfg.Listen).
+		Msg("Listening for webhooks")
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed serving webhooks: %w", err)
+	}
+
+	return nil
+}
+
+// Wait stops pending checks and waits for running checks to finish.
+func (s *webhookServer) Wait() {
+	s.mu.Lock()
+	for key, t := range s.pending {
+		if t.Stop() {
+			s.checks.Done()
+		}
+		delete(s.pending, key)
+	}
+	s.mu.Unlock()
+
+	s.checks.Wait()
+}
+
+// ServeHTTP accepts webhooks at /webhook/{pvr}.
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pvrName := strings.Trim(strings.TrimPrefix(r.URL.Path, "/webhook/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/webhook/") || pvrName == "" || strings.Contains(pvrName, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorised(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	l := log.With().
+		Str("pvr", pvrName).
+		Logger()
+
+	// get pvr
+	client, err := s.clients.get(pvrName)
+	if err != nil {
+		l.Warn().
+			Err(err).
+			Msg("Failed initialising pvr for webhook")
+		http.NotFound(w, r)
+		return
+	}
+
+	pvr, ok := client.pvr.(plexarr.WebhookPvr)
+	if !ok {
+		http.Error(w, "pvr does not support webhooks", http.StatusBadRequest)
+		return
+	}
+
+	// parse event
+	event, err := pvr.ParseWebhook(r.Body)
+	if err != nil {
+		l.Warn().
+			Err(err).
+			Msg("Failed parsing webhook")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if event == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	l.Info().
+		Str("event", event.Type).
+		Str("pvr_path", event.Item.PvrPath).
+		Str("file", event.File).
+		Msg("Received webhook")
+
+	s.schedule(pvrName, client.libraryType, event, 1)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *webhookServer) authorised(r *http.Request) bool {
+	secret := r.URL.Query().Get("secret")
+	if _, password, ok := r.BasicAuth(); ok {
+		secret = password
+	}
+
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(s.cfg.Secret)) == 1
+}
+
+// schedule checks the event item once the delay has passed, replacing a pending check of the same item.
+func (s *webhookServer) schedule(pvrName string, libraryType plexarr.LibraryType, event *plexarr.WebhookEvent,
+	attempt int) {
+	key := fmt.Sprintf("%s:%s", strings.ToLower(pvrName), event.Item.Path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if t, ok := s.pending[key]; ok && t.Stop() {
+		s.checks.Done()
+	}
+
+	s.checks.Add(1)
+	s.pending[key] = time.AfterFunc(s.cfg.Delay, func() {
+		defer s.checks.Done()
+
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+
+		s.check(pvrName, libraryType, event, attempt)
+	})
+}
+
+func (s *webhookServer) check(pvrName string, libraryType plexarr.LibraryType, event *plexarr.WebhookEvent,
+	attempt int) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	l := log.With().
+		Str("pvr", pvrName).
+		Str("path", event.Item.Path).
+		Int("attempt", attempt).
+		Bool("dry_run", s.cfg.DryRun).
+		Logger()
+
+	// wait until plex has scanned the item
+	plexItem, library, err := s.plex.GetItemByPath(s.ctx, libraryType, event.Item.Path, s.clients.cfg.normalize)
+	if err == nil && event.File != "" {
+		scanned, ferr := s.plex.HasFile(s.ctx, event.File, plexItem.Path, s.clients.cfg.normalize)
+		switch {
+		case ferr != nil:
+			err = ferr
+		case !scanned:
+			err = fmt.Errorf("%v: %w", event.File, plexarr.ErrItemNotFound)
+		}
+	}
+
+	switch {
+	case errors.Is(err, plexarr.ErrItemNotFound):
+		if attempt >= s.cfg.Retries {
+			l.Warn().
+				Err(err).
+				Msg("Plex has not scanned the item, giving up")
+			return
+		}
+
+		l.Debug().
+			Err(err).
+			Msg("Plex has not scanned the item yet, retrying later")
+		s.schedule(pvrName, libraryType, event, attempt+1)
+		return
+	case err != nil:
+		l.Error().
+			Err(err).
+			Msg("Failed retrieving plex item for webhook")
+		return
+	}
+
+	// validate match
+	plexGuids, err := getPlexGuids(*plexItem)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Interface("plex_item", plexItem).
+			Msg("Failed preparing plex guids for comparison")
+		return
+	}
+
+	if guidsMatched(plexGuids, event.Item.GUID) {
+		l.Info().
+			Str("plex_guid", plexItem.GUID).
+			Msg("Match validated")
+		return
+	}
+
+	// fix match
+	r := newRunner(s.plex, s.journal, s.cfg.DryRun)
+	if _, err := r.fixItems(s.ctx, []matchedItem{{
+		Library:      library,
+		PlexItem:     *plexItem,
+		PvrItem:      event.Item,
+		GuidStrategy: s.clients.guidStrategy(pvrName, library.Name),
+	}}); err != nil && !errors.Is(err, context.Canceled) {
+		l.Error().
+			Err(err).
+			Msg("Failed fixing match for webhook")
+	}
+}
@@ -0,0 +1,75 @@
+package plexarr
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	GuidTvdb = "tvdb"
+	GuidTmdb = "tmdb"
+	GuidImdb = "imdb"
+	GuidMbid = "mbid"
+)
+
+// DefaultGuidPreference prefers tvdb, then imdb guids.
+var DefaultGuidPreference = []string{GuidTvdb, GuidImdb}
+
+// guidProviders are the providers of pvr guids, by their agent name.
+var guidProviders = map[string]string{
+	"thetvdb":    GuidTvdb,
+	GuidTvdb:     GuidTvdb,
+	"themoviedb": GuidTmdb,
+	GuidTmdb:     GuidTmdb,
+	GuidImdb:     GuidImdb,
+	GuidMbid:     GuidMbid,
+}
+
+// GuidStrategy returns the guid of a pvr item that plex items are matched with.
+type GuidStrategy func(guids []string) string
+
+// NewGuidStrategy returns a strategy preferring the guid of the first provider present, otherwise the first guid.
+func NewGuidStrategy(providers []string) (GuidStrategy, error) {
+	if len(providers) == 0 {
+		providers = DefaultGuidPreference
+	}
+
+	preference := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		p, ok := guidProviders[strings.ToLower(provider)]
+		if !ok {
+			return nil, fmt.Errorf("invalid guid provider %q: must be one of %v, %v, %v or %v", provider,
+				GuidTvdb, GuidTmdb, GuidImdb, GuidMbid)
+		}
+
+		preference = append(preference, p)
+	}
+
+	strategy := func(guids []string) string {
+		if len(guids) == 0 {
+			return ""
+		}
+
+		for _, provider := range preference {
+			for _, guid := range guids {
+				if GuidProvider(guid) == provider {
+					return guid
+				}
+			}
+		}
+
+		return guids[0]
+	}
+
+	return strategy, nil
+}
+
+// GuidProvider returns the provider of a guid, e.g. tmdb for com.plexapp.agents.themoviedb://949.
+func GuidProvider(guid string) string {
+	parts := strings.SplitN(strings.TrimPrefix(guid, "com.plexapp.agents."), "://", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	return guidProviders[strings.ToLower(parts[0])]
+}
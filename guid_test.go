@@ -0,0 +1,70 @@
+package plexarr
+
+import (
+	"testing"
+)
+
+func TestNewGuidStrategy(t *testing.T) {
+	movie := []string{
+		"com.plexapp.agents.imdb://tt0078748",
+		"com.plexapp.agents.themoviedb://348",
+	}
+
+	tests := []struct {
+		name      string
+		providers []string
+		guids     []string
+		want      string
+		wantErr   bool
+	}{
+		{name: "default prefers imdb over tmdb", guids: movie, want: "com.plexapp.agents.imdb://tt0078748"},
+		{
+			name:  "default prefers tvdb",
+			guids: []string{"com.plexapp.agents.imdb://tt0903747", "com.plexapp.agents.thetvdb://81189"},
+			want:  "com.plexapp.agents.thetvdb://81189",
+		},
+		{
+			name:      "first preferred provider present",
+			providers: []string{"tmdb", "imdb"},
+			guids:     movie,
+			want:      "com.plexapp.agents.themoviedb://348",
+		},
+		{
+			name:      "agent names and case are accepted",
+			providers: []string{"TheMovieDB"},
+			guids:     movie,
+			want:      "com.plexapp.agents.themoviedb://348",
+		},
+		{
+			name:      "skips providers the item lacks",
+			providers: []string{"tvdb", "tmdb"},
+			guids:     movie,
+			want:      "com.plexapp.agents.themoviedb://348",
+		},
+		{
+			name:      "falls back to the first guid",
+			providers: []string{"mbid"},
+			guids:     []string{"com.plexapp.agents.themoviedb://348", "com.plexapp.agents.imdb://tt0078748"},
+			want:      "com.plexapp.agents.themoviedb://348",
+		},
+		{name: "no guids", providers: []string{"imdb"}, guids: nil, want: ""},
+		{name: "invalid provider", providers: []string{"imdb", "anidb"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := NewGuidStrategy(tt.providers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGuidStrategy(%q) error = %v, wantErr %v", tt.providers, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := strategy(tt.guids); got != tt.want {
+				t.Errorf("strategy(%q) = %q, want %q", tt.guids, got, tt.want)
+			}
+		})
+	}
+}
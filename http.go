@@ -0,0 +1,78 @@
+package plexarr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// responseHeaderTimeout bounds the wait for a response, while large library responses may take longer to read.
+const responseHeaderTimeout = time.Minute
+
+// HTTPConfig configures the http client used to talk to a plex / pvr instance.
+// Timeout limits whole requests, including reading the response, and is unset by default.
+type HTTPConfig struct {
+	Timeout            time.Duration `yaml:"timeout"`
+	Proxy              string        `yaml:"proxy"`
+	CACert             string        `yaml:"ca_cert"`
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+
+	// Client is used as-is when set, e.g. when plexarr is used as a library.
+	Client *http.Client `yaml:"-"`
+}
+
+func NewHTTPClient(c HTTPConfig) (*http.Client, error) {
+	if c.Client != nil {
+		return c.Client, nil
+	}
+
+	// dial and tls handshake timeouts are those of the default transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+
+	// proxy
+	if c.Proxy != "" {
+		proxy, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	// tls
+	if c.CACert != "" || c.InsecureSkipVerify {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: c.InsecureSkipVerify,
+		}
+
+		if c.CACert != "" {
+			pem, err := ioutil.ReadFile(c.CACert)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading ca certificate: %w", err)
+			}
+
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in ca certificate: %v", c.CACert)
+			}
+
+			tlsConfig.RootCAs = pool
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{
+		Timeout:   c.Timeout,
+		Transport: transport,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package plexarr
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"path"
+	"strings"
+)
+
+const (
+	// NormalizeWindows converts windows separators, e.g. D:\Movies\Alien (1979) to D:/Movies/Alien (1979)
+	NormalizeWindows = "windows"
+	// NormalizeClean removes duplicate separators and . / .. elements
+	NormalizeClean = "clean"
+	// NormalizeTrailingSlash removes trailing separators
+	NormalizeTrailingSlash = "trailing_slash"
+	// NormalizeUnicode composes characters (NFC), macOS and some SMB shares decompose them (NFD)
+	NormalizeUnicode = "unicode"
+	// NormalizeCase lower cases paths, for case-insensitive mounts
+	NormalizeCase = "case"
+)
+
+var normalizers = map[string]func(string) string{
+	NormalizeWindows: func(p string) string {
+		return strings.ReplaceAll(p, `\`, "/")
+	},
+	NormalizeClean: func(p string) string {
+		if p == "" {
+			return p
+		}
+		return path.Clean(p)
+	},
+	NormalizeTrailingSlash: func(p string) string {
+		if trimmed := strings.TrimRight(p, "/"); trimmed != "" {
+			return trimmed
+		}
+		return p
+	},
+	NormalizeUnicode: norm.NFC.String,
+	NormalizeCase:    strings.ToLower,
+}
+
+// Normalizer normalizes plex and pvr paths before they are compared.
+type Normalizer func(string) string
+
+// NewNormalizer returns a normalizer applying the steps in order.
+func NewNormalizer(steps []string) (Normalizer, error) {
+	fns := make([]func(string) string, 0, len(steps))
+	for _, step := range steps {
+		fn, ok := normalizers[strings.ToLower(step)]
+		if !ok {
+			return nil, fmt.Errorf("invalid normalize step %q: must be one of %v, %v, %v, %v or %v", step,
+				NormalizeWindows, NormalizeClean, NormalizeTrailingSlash, NormalizeUnicode, NormalizeCase)
+		}
+
+		fns = append(fns, fn)
+	}
+
+	normalizer := func(input string) string {
+		for _, fn := range fns {
+			input = fn(input)
+		}
+
+		return input
+	}
+
+	return normalizer, nil
+}
@@ -0,0 +1,55 @@
+package plexarr
+
+import (
+	"testing"
+)
+
+func TestNewNormalizer(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "no steps", steps: nil, input: `D:\Movies\`, want: `D:\Movies\`},
+		{name: "windows", steps: []string{"windows"}, input: `D:\Movies\Alien (1979)`, want: "D:/Movies/Alien (1979)"},
+		{name: "clean", steps: []string{"clean"}, input: "/data//Movies/./x/../Alien", want: "/data/Movies/Alien"},
+		{name: "clean empty", steps: []string{"clean"}, input: "", want: ""},
+		{name: "trailing slash", steps: []string{"trailing_slash"}, input: "/data/Movies//", want: "/data/Movies"},
+		{name: "trailing slash root", steps: []string{"trailing_slash"}, input: "/", want: "/"},
+		{name: "unicode", steps: []string{"unicode"}, input: "/data/Ame\u0301lie (2001)", want: "/data/Am\u00e9lie (2001)"},
+		{name: "case", steps: []string{"case"}, input: "/data/Movies/ALIEN (1979)", want: "/data/movies/alien (1979)"},
+		{name: "step names ignore case", steps: []string{"CASE"}, input: "/Data", want: "/data"},
+		{
+			name:  "steps in order",
+			steps: []string{"windows", "trailing_slash", "case"},
+			input: `D:\Movies\Alien (1979)\`,
+			want:  "d:/movies/alien (1979)",
+		},
+		{
+			name:  "order matters",
+			steps: []string{"trailing_slash", "windows"},
+			input: `D:\Movies\`,
+			want:  "D:/Movies/",
+		},
+		{name: "invalid step", steps: []string{"windows", "lower"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalize, err := NewNormalizer(tt.steps)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewNormalizer(%q) error = %v, wantErr %v", tt.steps, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := normalize(tt.input); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
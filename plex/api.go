@@ -1,16 +1,25 @@
 package plex
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/l3uddz/plexarr"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
-func (c *Client) Available() error {
+func (c *Client) Available(ctx context.Context) error {
+	return c.retry.DoRead(ctx, c.log, func() error {
+		return c.available(ctx)
+	})
+}
+
+func (c *Client) available(ctx context.Context) error {
 	// create request
-	req, err := http.NewRequest("GET", plexarr.JoinURL(c.url, "myplex", "account"), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", plexarr.JoinURL(c.url, "myplex", "account"), nil)
 	if err != nil {
 		return fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
 	}
@@ -20,7 +29,7 @@ func (c *Client) Available() error {
 	req.Header.Set("Accept", "application/json")
 
 	// send request
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.http.Do(req)
 	if err != nil {
 		return fmt.Errorf("could not check Plex availability: %v: %w",
 			err, plexarr.ErrPlexUnavailable)
@@ -31,15 +40,23 @@ func (c *Client) Available() error {
 	// validate response
 	if res.StatusCode != 200 {
 		return fmt.Errorf("could not check Plex availability: %v: %w",
-			res.StatusCode, plexarr.ErrPlexUnavailable)
+			res.StatusCode, plexarr.StatusError(res.StatusCode, plexarr.ErrPlexUnavailable))
 	}
 
 	return nil
 }
 
-func (c *Client) Split(metadataItemId int) error {
+// Split splits the media of an item into separate items.
+// Splits are not idempotent, so only requests plex rejected are retried, not those failing in transit.
+func (c *Client) Split(ctx context.Context, metadataItemId int) error {
+	return c.retry.DoWrite(ctx, c.log, func() error {
+		return c.split(ctx, metadataItemId)
+	})
+}
+
+func (c *Client) split(ctx context.Context, metadataItemId int) error {
 	// create request
-	req, err := http.NewRequest("PUT",
+	req, err := http.NewRequestWithContext(ctx, "PUT",
 		plexarr.JoinURL(c.url, "library", "metadata", strconv.Itoa(metadataItemId), "split"), nil)
 	if err != nil {
 		return fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
@@ -49,10 +66,10 @@ func (c *Client) Split(metadataItemId int) error {
 	req.Header.Set("X-Plex-Token", c.token)
 
 	// send request
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.http.Do(req)
 	if err != nil {
 		return fmt.Errorf("could not split Plex metadata_item %v: %v: %w",
-			metadataItemId, err, plexarr.ErrPlexUnavailable)
+			metadataItemId, err, plexarr.ErrUnconfirmedChange)
 	}
 
 	defer res.Body.Close()
@@ -60,15 +77,21 @@ func (c *Client) Split(metadataItemId int) error {
 	// validate response
 	if res.StatusCode != 200 {
 		return fmt.Errorf("could not split Plex metadata_item %v: %v: %w",
-			metadataItemId, res.StatusCode, plexarr.ErrFatal)
+			metadataItemId, res.StatusCode, plexarr.StatusError(res.StatusCode, plexarr.ErrPlexUnavailable))
 	}
 
 	return nil
 }
 
-func (c *Client) Match(metadataItemId int, title string, guid string) error {
+func (c *Client) Match(ctx context.Context, metadataItemId int, title string, guid string) error {
+	return c.retry.DoWrite(ctx, c.log, func() error {
+		return c.match(ctx, metadataItemId, title, guid)
+	})
+}
+
+func (c *Client) match(ctx context.Context, metadataItemId int, title string, guid string) error {
 	// create request
-	req, err := http.NewRequest("PUT",
+	req, err := http.NewRequestWithContext(ctx, "PUT",
 		plexarr.JoinURL(c.url, "library", "metadata", strconv.Itoa(metadataItemId), "match"), nil)
 	if err != nil {
 		return fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
@@ -85,7 +108,7 @@ func (c *Client) Match(metadataItemId int, title string, guid string) error {
 	req.URL.RawQuery = q.Encode()
 
 	// send request
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.http.Do(req)
 	if err != nil {
 		return fmt.Errorf("could not match Plex metadata_item %v: %v: %w",
 			metadataItemId, err, plexarr.ErrPlexUnavailable)
@@ -96,7 +119,270 @@ func (c *Client) Match(metadataItemId int, title string, guid string) error {
 	// validate response
 	if res.StatusCode != 200 {
 		return fmt.Errorf("could not match Plex metadata_item %v: %v: %w",
-			metadataItemId, res.StatusCode, plexarr.ErrFatal)
+			metadataItemId, res.StatusCode, plexarr.StatusError(res.StatusCode, plexarr.ErrPlexUnavailable))
+	}
+
+	return nil
+}
+
+func (c *Client) Unmatch(ctx context.Context, metadataItemId int) error {
+	return c.retry.DoWrite(ctx, c.log, func() error {
+		return c.unmatch(ctx, metadataItemId)
+	})
+}
+
+func (c *Client) unmatch(ctx context.Context, metadataItemId int) error {
+	// create request
+	req, err := http.NewRequestWithContext(ctx, "PUT",
+		plexarr.JoinURL(c.url, "library", "metadata", strconv.Itoa(metadataItemId), "unmatch"), nil)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
+	}
+
+	// set headers
+	req.Header.Set("X-Plex-Token", c.token)
+
+	// send request
+	res, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not unmatch Plex metadata_item %v: %v: %w",
+			metadataItemId, err, plexarr.ErrPlexUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	// validate response
+	if res.StatusCode != 200 {
+		return fmt.Errorf("could not unmatch Plex metadata_item %v: %v: %w",
+			metadataItemId, res.StatusCode, plexarr.StatusError(res.StatusCode, plexarr.ErrPlexUnavailable))
+	}
+
+	return nil
+}
+
+type matchesResponse struct {
+	MediaContainer struct {
+		SearchResult []searchResult `json:"SearchResult"`
+	} `json:"MediaContainer"`
+}
+
+type searchResult struct {
+	GUID  string `json:"guid"`
+	Name  string `json:"name"`
+	Year  int    `json:"year"`
+	Score int    `json:"score"`
+	Guid  []struct {
+		ID string `json:"id"`
+	} `json:"Guid"`
+}
+
+// confidentScore is the score of search results matching the searched id exactly.
+const confidentScore = 100
+
+func (c *Client) GetPlexGuid(ctx context.Context, metadataItemId int, libraryType plexarr.LibraryType, guid string,
+	title string) (string, error) {
+	// determine agent and search term, e.g. imdb-tt0111161
+	agent, term := "", ""
+	switch libraryType {
+	case plexarr.MovieLibrary:
+		agent = "tv.plex.agents.movie"
+	case plexarr.TvLibrary:
+		agent = "tv.plex.agents.series"
+	case plexarr.MusicLibrary:
+		// the music agent cannot search by id, results are verified by their mbid
+		agent, term = "tv.plex.agents.music", title
+	default:
+		return "", fmt.Errorf("unsupported library type for plex agent: %v: %w", libraryType, plexarr.ErrFatal)
+	}
+
+	externalGuid, err := plexExternalGuid(guid)
+	if err != nil {
+		return "", err
+	}
+
+	if term == "" {
+		t, err := plexSearchTerm(guid)
+		if err != nil {
+			return "", err
+		}
+		term = t
+	}
+
+	results := make([]searchResult, 0)
+	err = c.retry.DoRead(ctx, c.log, func() error {
+		r, err := c.searchPlexGuid(ctx, metadataItemId, agent, term)
+		results = r
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return selectPlexGuid(results, externalGuid, libraryType == plexarr.MusicLibrary)
+}
+
+// selectPlexGuid returns the plex guid of the search result with the external guid searched for.
+// Unless required, results without external guids are accepted when a single result has a confident score.
+func selectPlexGuid(results []searchResult, externalGuid string, requireGuid bool) (string, error) {
+	withGuids := false
+	confident := make([]string, 0)
+	for _, result := range results {
+		if !strings.HasPrefix(result.GUID, "plex://") {
+			continue
+		}
+
+		for _, guid := range result.Guid {
+			withGuids = true
+			if strings.EqualFold(guid.ID, externalGuid) {
+				return result.GUID, nil
+			}
+		}
+
+		if result.Score >= confidentScore {
+			confident = append(confident, result.GUID)
+		}
+	}
+
+	switch {
+	case withGuids || requireGuid:
+		return "", fmt.Errorf("no plex match has external guid %v: %w", externalGuid, plexarr.ErrNoConfidentMatch)
+	case len(confident) != 1:
+		return "", fmt.Errorf("%d plex matches with a confident score for %v: %w", len(confident), externalGuid,
+			plexarr.ErrNoConfidentMatch)
+	default:
+		return confident[0], nil
+	}
+}
+
+func (c *Client) searchPlexGuid(ctx context.Context, metadataItemId int, agent string,
+	term string) ([]searchResult, error) {
+	// create request
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		plexarr.JoinURL(c.url, "library", "metadata", strconv.Itoa(metadataItemId), "matches"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
+	}
+
+	// set headers
+	req.Header.Set("X-Plex-Token", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	// set params
+	q := url.Values{}
+	q.Set("manual", "1")
+	q.Set("agent", agent)
+	q.Set("title", term)
+	q.Set("includeGuids", "1")
+
+	req.URL.RawQuery = q.Encode()
+
+	// send request
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not search matches for Plex metadata_item %v: %v: %w",
+			metadataItemId, err, plexarr.ErrPlexUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	// validate response
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("could not search matches for Plex metadata_item %v: %v: %w",
+			metadataItemId, res.StatusCode, plexarr.StatusError(res.StatusCode, plexarr.ErrPlexUnavailable))
+	}
+
+	// decode response
+	b := new(matchesResponse)
+	if err := json.NewDecoder(res.Body).Decode(b); err != nil {
+		return nil, fmt.Errorf("could not decode matches for Plex metadata_item %v: %v: %w",
+			metadataItemId, err, plexarr.ErrFatal)
+	}
+
+	return b.MediaContainer.SearchResult, nil
+}
+
+// plexSearchTerm converts a legacy agent guid into the search syntax understood by the plex agents.
+func plexSearchTerm(guid string) (string, error) {
+	provider, id, err := parseAgentGuid(guid)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s", provider, id), nil
+}
+
+// plexExternalGuid converts a legacy agent guid into the external guid of plex agent items, e.g. tmdb://949.
+func plexExternalGuid(guid string) (string, error) {
+	provider, id, err := parseAgentGuid(guid)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s://%s", provider, id), nil
+}
+
+func parseAgentGuid(guid string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(guid, "com.plexapp.agents."), "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("unable to parse guid: %v: %w", guid, plexarr.ErrUnmatchable)
+	}
+
+	id := strings.SplitN(parts[1], "?", 2)[0]
+	switch parts[0] {
+	case "imdb":
+		return "imdb", id, nil
+	case "themoviedb", "tmdb":
+		return "tmdb", id, nil
+	case "thetvdb", "tvdb":
+		return "tvdb", id, nil
+	case "mbid":
+		return "mbid", id, nil
+	default:
+		return "", "", fmt.Errorf("unsupported guid provider: %v: %w", guid, plexarr.ErrUnmatchable)
+	}
+}
+
+// getJson retrieves and decodes a json response from the plex api.
+func (c *Client) getJson(ctx context.Context, query url.Values, v interface{}, path ...string) error {
+	return c.retry.DoRead(ctx, c.log, func() error {
+		return c.fetchJson(ctx, query, v, path...)
+	})
+}
+
+func (c *Client) fetchJson(ctx context.Context, query url.Values, v interface{}, path ...string) error {
+	// create request
+	req, err := http.NewRequestWithContext(ctx, "GET", plexarr.JoinURL(c.url, path...), nil)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
+	}
+
+	req.URL.RawQuery = query.Encode()
+
+	// set headers
+	req.Header.Set("X-Plex-Token", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	// send request
+	res, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not request %v: %v: %w", req.URL.Path, err, plexarr.ErrPlexUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	// validate response
+	if res.StatusCode == 404 {
+		return fmt.Errorf("could not request %v: %v: %w", req.URL.Path, res.StatusCode, plexarr.ErrItemNotFound)
+	}
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("could not request %v: %v: %w", req.URL.Path, res.StatusCode,
+			plexarr.StatusError(res.StatusCode, plexarr.ErrPlexUnavailable))
+	}
+
+	// decode response
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode %v: %v: %w", req.URL.Path, err, plexarr.ErrFatal)
 	}
 
 	return nil
@@ -0,0 +1,464 @@
+package plex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// apiStore reads library contents from the plex api, for servers whose database is not reachable.
+type apiStore struct {
+	client *Client
+}
+
+type apiSectionsResponse struct {
+	MediaContainer struct {
+		Directory []struct {
+			Key      string `json:"key"`
+			Title    string `json:"title"`
+			Type     string `json:"type"`
+			Agent    string `json:"agent"`
+			UUID     string `json:"uuid"`
+			Location []struct {
+				ID   int    `json:"id"`
+				Path string `json:"path"`
+			} `json:"Location"`
+		} `json:"Directory"`
+	} `json:"MediaContainer"`
+}
+
+type apiMetadata struct {
+	RatingKey            string `json:"ratingKey"`
+	GrandparentRatingKey string `json:"grandparentRatingKey"`
+	GUID                 string `json:"guid"`
+	Title                string `json:"title"`
+	Year                 int    `json:"year"`
+	Index                int    `json:"index"`
+	ParentIndex          int    `json:"parentIndex"`
+	Guid                 []struct {
+		ID string `json:"id"`
+	} `json:"Guid"`
+	Media []struct {
+		Part []struct {
+			File string `json:"file"`
+		} `json:"Part"`
+	} `json:"Media"`
+}
+
+type apiMetadataResponse struct {
+	MediaContainer struct {
+		TotalSize int           `json:"totalSize"`
+		Metadata  []apiMetadata `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+// apiPageSize is the number of items requested at once from library sections.
+const apiPageSize = 500
+
+// metadata types of the top-level items and their media (leaves) per library type
+var apiMetadataTypes = map[plexarr.LibraryType][2]int{
+	plexarr.MovieLibrary: {1, 1},
+	plexarr.TvLibrary:    {2, 4},
+	plexarr.MusicLibrary: {8, 10},
+}
+
+var apiLibraryTypes = map[string]plexarr.LibraryType{
+	"movie":  plexarr.MovieLibrary,
+	"show":   plexarr.TvLibrary,
+	"artist": plexarr.MusicLibrary,
+}
+
+func (s *apiStore) Libraries(ctx context.Context) ([]Library, error) {
+	b := new(apiSectionsResponse)
+	if err := s.client.getJson(ctx, nil, b, "library", "sections"); err != nil {
+		return nil, fmt.Errorf("retrieve library sections: %w", err)
+	}
+
+	libraries := make([]Library, 0)
+	for _, d := range b.MediaContainer.Directory {
+		libType, ok := apiLibraryTypes[d.Type]
+		if !ok {
+			continue
+		}
+
+		id, err := strconv.Atoi(d.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid library section key: %v", d.Key)
+		}
+
+		lib := Library{
+			ID:        id,
+			Name:      d.Title,
+			Type:      libType,
+			Agent:     d.Agent,
+			UUID:      d.UUID,
+			Locations: make([]Location, 0, len(d.Location)),
+		}
+
+		for _, loc := range d.Location {
+			lib.Locations = append(lib.Locations, Location{ID: loc.ID, Path: loc.Path})
+		}
+
+		libraries = append(libraries, lib)
+	}
+
+	return libraries, nil
+}
+
+func (s *apiStore) GetMediaItems(ctx context.Context, libraryId int) ([]MediaItem, error) {
+	lib := s.library(libraryId)
+	if lib == nil {
+		return nil, fmt.Errorf("no library found with id: %v", libraryId)
+	}
+
+	types := apiMetadataTypes[lib.Type]
+
+	// retrieve top-level items, e.g. movies / shows, and their media
+	top, err := s.sectionItems(ctx, libraryId, types[0])
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := top
+	if types[1] != types[0] {
+		leaves, err = s.sectionItems(ctx, libraryId, types[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	owners := make(map[string]apiMetadata, len(top))
+	for _, item := range top {
+		owners[item.RatingKey] = item
+	}
+
+	// an item per top-level folder, like the database
+	items := make(map[string]MediaItem)
+	for _, leaf := range leaves {
+		owner := leaf
+		if types[1] != types[0] {
+			o, ok := owners[leaf.GrandparentRatingKey]
+			if !ok {
+				continue
+			}
+			owner = o
+		}
+
+		for _, file := range leaf.files() {
+			loc, path := topLevelPath(lib.Locations, file)
+			if _, exists := items[path]; path == "" || exists {
+				continue
+			}
+
+			item, err := owner.mediaItem(libraryId, *loc, path)
+			if err != nil {
+				return nil, err
+			}
+
+			items[path] = *item
+		}
+	}
+
+	mediaItems := make([]MediaItem, 0, len(items))
+	for _, item := range items {
+		mediaItems = append(mediaItems, item)
+	}
+
+	sort.Slice(mediaItems, func(i, j int) bool {
+		return mediaItems[i].Path < mediaItems[j].Path
+	})
+
+	return mediaItems, nil
+}
+
+func (s *apiStore) GetMediaItem(ctx context.Context, sectionId int, childPath string) (*MediaItem, error) {
+	for i, lib := range s.client.libraries {
+		for _, loc := range lib.Locations {
+			if loc.ID != sectionId {
+				continue
+			}
+
+			path := filepath.Join(loc.Path, childPath)
+			leaves, err := s.fileItems(ctx, &s.client.libraries[i], path+"/")
+			if err != nil {
+				return nil, err
+			}
+
+			for _, leaf := range leaves {
+				for _, file := range leaf.files() {
+					if _, p := topLevelPath([]Location{loc}, file); p != path {
+						continue
+					}
+
+					// episodes / tracks belong to their show / artist
+					owner := &leaf
+					if apiMetadataTypes[lib.Type][1] != apiMetadataTypes[lib.Type][0] {
+						id, err := strconv.ParseUint(leaf.GrandparentRatingKey, 10, 64)
+						if err != nil {
+							return nil, fmt.Errorf("invalid grandparent rating key: %v", leaf.GrandparentRatingKey)
+						}
+
+						if owner, err = s.metadata(ctx, id); err != nil {
+							return nil, err
+						}
+					}
+
+					return owner.mediaItem(lib.ID, loc, path)
+				}
+			}
+		}
+	}
+
+	return nil, plexarr.ErrItemNotFound
+}
+
+func (s *apiStore) HasMediaPart(ctx context.Context, file string) (bool, error) {
+	for i, lib := range s.client.libraries {
+		if _, path := topLevelPath(lib.Locations, file); path == "" {
+			continue
+		}
+
+		leaves, err := s.fileItems(ctx, &s.client.libraries[i], file)
+		if err != nil {
+			return false, err
+		}
+
+		for _, leaf := range leaves {
+			for _, f := range leaf.files() {
+				if f == file {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (s *apiStore) GetMediaParts(ctx context.Context, folder string) ([]string, error) {
+	files := make([]string, 0)
+	for i, lib := range s.client.libraries {
+		if loc, _ := topLevelPath(lib.Locations, filepath.Join(folder, "file")); loc == nil {
+			continue
+		}
+
+		leaves, err := s.fileItems(ctx, &s.client.libraries[i], folder+"/")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, leaf := range leaves {
+			for _, f := range leaf.files() {
+				if strings.HasPrefix(f, folder+"/") {
+					files = append(files, f)
+				}
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func (s *apiStore) GetEpisodes(ctx context.Context, showMetadataId uint64) (map[string][]plexarr.Episode, error) {
+	leaves, err := s.leaves(ctx, showMetadataId)
+	if err != nil {
+		return nil, err
+	}
+
+	episodes := make(map[string][]plexarr.Episode)
+	for _, leaf := range leaves {
+		for _, file := range leaf.files() {
+			episodes[file] = append(episodes[file], plexarr.Episode{
+				Season:  leaf.ParentIndex,
+				Episode: leaf.Index,
+			})
+		}
+	}
+
+	return episodes, nil
+}
+
+func (s *apiStore) GetMetadataGuid(ctx context.Context, metadataItemId uint64) (string, error) {
+	item, err := s.metadata(ctx, metadataItemId)
+	if err != nil {
+		return "", err
+	}
+
+	return item.GUID, nil
+}
+
+func (s *apiStore) CountMediaItems(ctx context.Context, metadataItemId uint64) (int, error) {
+	item, err := s.metadata(ctx, metadataItemId)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(item.Media) > 0 {
+		return len(item.Media), nil
+	}
+
+	// shows / artists have media through their episodes / tracks
+	leaves, err := s.leaves(ctx, metadataItemId)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, leaf := range leaves {
+		count += len(leaf.Media)
+	}
+
+	return count, nil
+}
+
+func (s *apiStore) library(libraryId int) *Library {
+	for i, lib := range s.client.libraries {
+		if lib.ID == libraryId {
+			return &s.client.libraries[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *apiStore) sectionItems(ctx context.Context, libraryId int, metadataType int) ([]apiMetadata, error) {
+	q := url.Values{}
+	q.Set("type", strconv.Itoa(metadataType))
+	q.Set("includeGuids", "1")
+
+	items, err := s.pagedItems(ctx, q, "library", "sections", strconv.Itoa(libraryId), "all")
+	if err != nil {
+		return nil, fmt.Errorf("retrieve library items: %w", err)
+	}
+
+	return items, nil
+}
+
+// fileItems returns the media items (leaves) of a library with a file containing path.
+// Plex filters the items, so a single item is looked up without listing the library.
+func (s *apiStore) fileItems(ctx context.Context, lib *Library, path string) ([]apiMetadata, error) {
+	q := url.Values{}
+	q.Set("type", strconv.Itoa(apiMetadataTypes[lib.Type][1]))
+	q.Set("includeGuids", "1")
+	q.Set("file", path)
+
+	items, err := s.pagedItems(ctx, q, "library", "sections", strconv.Itoa(lib.ID), "all")
+	if err != nil {
+		return nil, fmt.Errorf("retrieve library items by file: %w", err)
+	}
+
+	return items, nil
+}
+
+// pagedItems retrieves the items of a container in pages.
+func (s *apiStore) pagedItems(ctx context.Context, query url.Values, path ...string) ([]apiMetadata, error) {
+	items := make([]apiMetadata, 0)
+	for {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("X-Plex-Container-Start", strconv.Itoa(len(items)))
+		q.Set("X-Plex-Container-Size", strconv.Itoa(apiPageSize))
+
+		b := new(apiMetadataResponse)
+		if err := s.client.getJson(ctx, q, b, path...); err != nil {
+			return nil, err
+		}
+
+		items = append(items, b.MediaContainer.Metadata...)
+		if len(b.MediaContainer.Metadata) < apiPageSize || len(items) >= b.MediaContainer.TotalSize {
+			return items, nil
+		}
+	}
+}
+
+func (s *apiStore) metadata(ctx context.Context, metadataItemId uint64) (*apiMetadata, error) {
+	q := url.Values{}
+	q.Set("includeGuids", "1")
+
+	b := new(apiMetadataResponse)
+	err := s.client.getJson(ctx, q, b, "library", "metadata", strconv.FormatUint(metadataItemId, 10))
+	switch {
+	case errors.Is(err, plexarr.ErrItemNotFound):
+		return nil, plexarr.ErrItemNotFound
+	case err != nil:
+		return nil, fmt.Errorf("retrieve metadata item: %w", err)
+	case len(b.MediaContainer.Metadata) == 0:
+		return nil, plexarr.ErrItemNotFound
+	}
+
+	return &b.MediaContainer.Metadata[0], nil
+}
+
+func (s *apiStore) leaves(ctx context.Context, metadataItemId uint64) ([]apiMetadata, error) {
+	items, err := s.pagedItems(ctx, url.Values{}, "library", "metadata", strconv.FormatUint(metadataItemId, 10),
+		"allLeaves")
+	if err != nil {
+		return nil, fmt.Errorf("retrieve metadata item leaves: %w", err)
+	}
+
+	return items, nil
+}
+
+func (m apiMetadata) files() []string {
+	files := make([]string, 0)
+	for _, media := range m.Media {
+		for _, part := range media.Part {
+			if part.File != "" {
+				files = append(files, part.File)
+			}
+		}
+	}
+
+	return files
+}
+
+func (m apiMetadata) mediaItem(libraryId int, loc Location, path string) (*MediaItem, error) {
+	id, err := strconv.ParseUint(m.RatingKey, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rating key: %v", m.RatingKey)
+	}
+
+	externalGuids := make([]string, 0, len(m.Guid))
+	for _, guid := range m.Guid {
+		externalGuids = append(externalGuids, guid.ID)
+	}
+
+	return &MediaItem{
+		LibraryId:     uint64(libraryId),
+		Location:      loc,
+		Path:          path,
+		MetadataId:    id,
+		GUID:          m.GUID,
+		ExternalGUIDs: externalGuids,
+		Title:         m.Title,
+		Year:          m.Year,
+	}, nil
+}
+
+// topLevelPath returns the top-level folder of a file within the library locations, e.g. the movie folder.
+func topLevelPath(locations []Location, file string) (*Location, string) {
+	for i, loc := range locations {
+		rel, err := filepath.Rel(loc.Path, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+		if len(parts) < 2 {
+			// files in the root of the location have no folder
+			continue
+		}
+
+		return &locations[i], filepath.Join(loc.Path, parts[0])
+	}
+
+	return nil, ""
+}
@@ -0,0 +1,90 @@
+package plex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"time"
+)
+
+// WaitLimit blocks until the rate limit allows another change to be made.
+func (c *Client) WaitLimit(ctx context.Context) error {
+	return c.limiter.Wait(ctx)
+}
+
+// GetGuid returns the current guid of a metadata item.
+func (c *Client) GetGuid(ctx context.Context, metadataItemId uint64) (string, error) {
+	return c.store.GetMetadataGuid(ctx, metadataItemId)
+}
+
+// CountMediaItems returns the number of media items attached to a metadata item, or its episodes / tracks.
+func (c *Client) CountMediaItems(ctx context.Context, metadataItemId uint64) (int, error) {
+	return c.store.CountMediaItems(ctx, metadataItemId)
+}
+
+// WaitForMatch waits until the guid of the metadata item is guid.
+func (c *Client) WaitForMatch(ctx context.Context, metadataItemId uint64, guid string) error {
+	return c.poll(ctx, func() (bool, error) {
+		current, err := c.store.GetMetadataGuid(ctx, metadataItemId)
+		if err != nil {
+			return false, err
+		}
+
+		return current == guid, nil
+	})
+}
+
+// WaitForUnmatch waits until the guid of the metadata item is no longer previousGuid.
+func (c *Client) WaitForUnmatch(ctx context.Context, metadataItemId uint64, previousGuid string) error {
+	return c.poll(ctx, func() (bool, error) {
+		current, err := c.store.GetMetadataGuid(ctx, metadataItemId)
+		if err != nil {
+			return false, err
+		}
+
+		return current != previousGuid, nil
+	})
+}
+
+// WaitForSplit waits until the metadata item has fewer than mediaItems media items attached.
+func (c *Client) WaitForSplit(ctx context.Context, metadataItemId uint64, mediaItems int) error {
+	return c.poll(ctx, func() (bool, error) {
+		count, err := c.store.CountMediaItems(ctx, metadataItemId)
+		if err != nil {
+			return false, err
+		}
+
+		return count < mediaItems, nil
+	})
+}
+
+func (c *Client) poll(ctx context.Context, completed func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, c.changeTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		ok, err := completed()
+		switch {
+		case errors.Is(err, plexarr.ErrItemNotFound):
+			// item removed by the change
+			return nil
+		case err != nil:
+			return err
+		case ok:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%v: %w", c.changeTimeout, plexarr.ErrChangeTimeout)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package plex
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/l3uddz/plexarr"
 	"net/url"
@@ -26,31 +28,71 @@ func newDatastore(path string) (*datastore, error) {
 }
 
 type datastore struct {
-	db *sql.DB
+	db     queryer
+	schema schema
+
+	// connection holding the read transaction, for snapshots
+	conn *sql.Conn
+}
+
+// queryer is the database, or a single connection of it.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-type library struct {
+type Library struct {
+	ID        int
+	Name      string
+	Type      plexarr.LibraryType
+	Agent     string
+	UUID      string
+	Locations []Location
+}
+
+// Location is a root folder of a library.
+type Location struct {
 	ID   int
-	Name string
-	Type plexarr.LibraryType
 	Path string
 }
 
-func (d *datastore) Libraries() ([]library, error) {
-	rows, err := d.db.Query(sqlSelectLibraries)
+// Location returns the location of the library containing path.
+func (l *Library) Location(path string) (*Location, bool) {
+	for i, loc := range l.Locations {
+		rel, err := filepath.Rel(loc.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		return &l.Locations[i], true
+	}
+
+	return nil, false
+}
+
+func (d *datastore) Libraries(ctx context.Context) ([]Library, error) {
+	rows, err := d.db.QueryContext(ctx, sqlSelectLibraries)
 	if err != nil {
 		return nil, fmt.Errorf("select libraries: %v", err)
 	}
 
 	defer rows.Close()
 
-	libraries := make([]library, 0)
+	libraries := make([]Library, 0)
 	for rows.Next() {
-		l := library{}
-		if err := rows.Scan(&l.ID, &l.Name, &l.Type, &l.Path); err != nil {
+		l := Library{}
+		loc := Location{}
+		if err := rows.Scan(&l.ID, &l.Name, &l.Type, &l.Agent, &l.UUID, &loc.ID, &loc.Path); err != nil {
 			return nil, fmt.Errorf("scan library row: %v", err)
 		}
 
+		// a row per location, ordered by library
+		if n := len(libraries); n > 0 && libraries[n-1].ID == l.ID {
+			libraries[n-1].Locations = append(libraries[n-1].Locations, loc)
+			continue
+		}
+
+		l.Locations = []Location{loc}
 		libraries = append(libraries, l)
 	}
 
@@ -58,20 +100,138 @@ func (d *datastore) Libraries() ([]library, error) {
 }
 
 type MediaItem struct {
-	LibraryId  uint64
-	Path       string
-	MetadataId uint64
-	GUID       string
+	LibraryId     uint64
+	Location      Location
+	Path          string
+	MetadataId    uint64
+	GUID          string
+	ExternalGUIDs []string
+	Title         string
+	Year          int
 }
 
-func (d *datastore) GetMediaItems(libraryId int) ([]MediaItem, error) {
-	rows, err := d.db.Query(sqlSelectLibraryItemsMetadata, libraryId)
+func (d *datastore) GetMediaItems(ctx context.Context, libraryId int) ([]MediaItem, error) {
+	rows, err := d.db.QueryContext(ctx, d.schema.sqlSelectLibraryItems, libraryId)
 	if err != nil {
 		return nil, fmt.Errorf("select media items: %v", err)
 	}
 
 	defer rows.Close()
 
+	return d.scanMediaItems(rows)
+}
+
+func (d *datastore) GetMediaItem(ctx context.Context, sectionId int, childPath string) (*MediaItem, error) {
+	rows, err := d.db.QueryContext(ctx, d.schema.sqlSelectSectionItem, sectionId, childPath)
+	if err != nil {
+		return nil, fmt.Errorf("select media item: %v", err)
+	}
+
+	defer rows.Close()
+
+	mediaItems, err := d.scanMediaItems(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mediaItems) == 0 {
+		return nil, plexarr.ErrItemNotFound
+	}
+
+	return &mediaItems[0], nil
+}
+
+func (d *datastore) HasMediaPart(ctx context.Context, file string) (bool, error) {
+	exists := false
+	if err := d.db.QueryRowContext(ctx, sqlSelectMediaPartExists, file).Scan(&exists); err != nil {
+		return false, fmt.Errorf("select media part: %v", err)
+	}
+
+	return exists, nil
+}
+
+func (d *datastore) GetMediaParts(ctx context.Context, folder string) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, sqlSelectFolderMediaParts, folder)
+	if err != nil {
+		return nil, fmt.Errorf("select media parts: %v", err)
+	}
+
+	defer rows.Close()
+
+	files := make([]string, 0)
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, fmt.Errorf("scan media part row: %v", err)
+		}
+
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate media part rows: %v", err)
+	}
+
+	return files, nil
+}
+
+// GetEpisodes returns the episodes attached to each file of a show.
+func (d *datastore) GetEpisodes(ctx context.Context, showMetadataId uint64) (map[string][]plexarr.Episode, error) {
+	rows, err := d.db.QueryContext(ctx, sqlSelectShowEpisodes, showMetadataId)
+	if err != nil {
+		return nil, fmt.Errorf("select episodes: %v", err)
+	}
+
+	defer rows.Close()
+
+	episodes := make(map[string][]plexarr.Episode)
+	for rows.Next() {
+		var file string
+		e := plexarr.Episode{}
+		if err := rows.Scan(&file, &e.Season, &e.Episode); err != nil {
+			return nil, fmt.Errorf("scan episode row: %v", err)
+		}
+
+		episodes[file] = append(episodes[file], e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate episode rows: %v", err)
+	}
+
+	return episodes, nil
+}
+
+func (d *datastore) GetMetadataGuid(ctx context.Context, metadataItemId uint64) (string, error) {
+	var guid *string
+	if err := d.db.QueryRowContext(ctx, sqlSelectMetadataGuid, metadataItemId).Scan(&guid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", plexarr.ErrItemNotFound
+		}
+		return "", fmt.Errorf("select metadata guid: %v", err)
+	}
+
+	if guid == nil {
+		return "", nil
+	}
+
+	return *guid, nil
+}
+
+func (d *datastore) CountMediaItems(ctx context.Context, metadataItemId uint64) (int, error) {
+	count := 0
+	if err := d.db.QueryRowContext(ctx, sqlSelectMediaItemsCount, metadataItemId).Scan(&count); err != nil {
+		return 0, fmt.Errorf("select media items count: %v", err)
+	}
+
+	return count, nil
+}
+
+func (d *datastore) scanMediaItems(rows *sql.Rows) ([]MediaItem, error) {
+	if !d.schema.directories {
+		return scanMediaFiles(rows)
+	}
+
 	mediaItems := make([]MediaItem, 0)
 	for rows.Next() {
 		m := new(struct {
@@ -84,39 +244,58 @@ func (d *datastore) GetMediaItems(libraryId int) ([]MediaItem, error) {
 			SectionChildDirectoryPath                      *string
 			SectionChildDirectoryMetadataItemId            *uint64
 			SectionChildDirectoryMetadataItemGuid          *string
+			SectionChildDirectoryMetadataItemTitle         *string
+			SectionChildDirectoryMetadataItemYear          *int
 			SectionChildDirectoryMetadataItemExternalGuids *string
 		})
 		if err := rows.Scan(&m.LibraryId, &m.LibraryName, &m.SectionId, &m.SectionPath, &m.SectionDirectoryId,
 			&m.SectionChildDirectoryId, &m.SectionChildDirectoryPath, &m.SectionChildDirectoryMetadataItemId,
-			&m.SectionChildDirectoryMetadataItemGuid, &m.SectionChildDirectoryMetadataItemExternalGuids); err != nil {
+			&m.SectionChildDirectoryMetadataItemGuid, &m.SectionChildDirectoryMetadataItemTitle,
+			&m.SectionChildDirectoryMetadataItemYear, &m.SectionChildDirectoryMetadataItemExternalGuids); err != nil {
 			return nil, fmt.Errorf("scan media item row: %v", err)
 		}
 
-		if m.LibraryId == nil || m.SectionPath == nil || m.SectionChildDirectoryPath == nil ||
+		if m.LibraryId == nil || m.SectionId == nil || m.SectionPath == nil || m.SectionChildDirectoryPath == nil ||
 			m.SectionChildDirectoryMetadataItemId == nil || m.SectionChildDirectoryMetadataItemGuid == nil {
 			return nil, fmt.Errorf("invalid media item row: %+v", m)
 		}
 
-		guid := ""
-		if strings.HasPrefix(*m.SectionChildDirectoryMetadataItemGuid, "plex://") {
-			// item has a plex guid - we are only able to handle this in specific scenarios
-			if m.SectionChildDirectoryMetadataItemExternalGuids == nil {
-				guid = fmt.Sprintf("local://%v", *m.SectionChildDirectoryMetadataItemId)
-			} else {
-				guid = *m.SectionChildDirectoryMetadataItemExternalGuids
+		// external guids are only present for items matched with the plex agents
+		externalGuids := make([]string, 0)
+		if m.SectionChildDirectoryMetadataItemExternalGuids != nil {
+			for _, guid := range strings.Split(*m.SectionChildDirectoryMetadataItemExternalGuids, ",") {
+				if guid != "" {
+					externalGuids = append(externalGuids, guid)
+				}
 			}
-		} else {
-			guid = *m.SectionChildDirectoryMetadataItemGuid
+		}
+
+		title := ""
+		if m.SectionChildDirectoryMetadataItemTitle != nil {
+			title = *m.SectionChildDirectoryMetadataItemTitle
+		}
+
+		year := 0
+		if m.SectionChildDirectoryMetadataItemYear != nil {
+			year = *m.SectionChildDirectoryMetadataItemYear
 		}
 
 		mediaItems = append(mediaItems, MediaItem{
-			LibraryId:  *m.LibraryId,
-			Path:       filepath.Join(*m.SectionPath, *m.SectionChildDirectoryPath),
-			MetadataId: *m.SectionChildDirectoryMetadataItemId,
-			GUID:       guid,
+			LibraryId:     *m.LibraryId,
+			Location:      Location{ID: int(*m.SectionId), Path: *m.SectionPath},
+			Path:          filepath.Join(*m.SectionPath, *m.SectionChildDirectoryPath),
+			MetadataId:    *m.SectionChildDirectoryMetadataItemId,
+			GUID:          *m.SectionChildDirectoryMetadataItemGuid,
+			ExternalGUIDs: externalGuids,
+			Title:         title,
+			Year:          year,
 		})
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate media item rows: %v", err)
+	}
+
 	return mediaItems, nil
 }
 
@@ -127,12 +306,18 @@ SELECT
     ls.id,
     ls.name,
     ls.section_type as type,
+    ls.agent,
+    ls.uuid,
+    sl.id,
     sl.root_path
 FROM
     library_sections ls
     JOIN section_locations sl ON sl.library_section_id = ls.id
+ORDER BY
+    ls.id,
+    sl.id
 `
-	sqlSelectLibraryItemsMetadata = `
+	sqlSelectItemsMetadata = `
 with ls as (
     SELECT
         ls.id AS library_id,
@@ -162,21 +347,77 @@ SELECT DISTINCT
         WHEN mti2.guid IS NOT NULL THEN mti2.guid
         WHEN mti.guid IS NOT NULL THEN mti.guid
         ELSE NULL
-    END AS child_directory_metadata_item_guid
-    , GROUP_CONCAT(DISTINCT t.tag) as child_directory_metadata_item_guids_external
+    END AS child_directory_metadata_item_guid,
+    CASE
+        WHEN mti3.guid IS NOT NULL THEN mti3.title
+        WHEN mti2.guid IS NOT NULL THEN mti2.title
+        WHEN mti.guid IS NOT NULL THEN mti.title
+        ELSE NULL
+    END AS child_directory_metadata_item_title,
+    CASE
+        WHEN mti3.guid IS NOT NULL THEN mti3.year
+        WHEN mti2.guid IS NOT NULL THEN mti2.year
+        WHEN mti.guid IS NOT NULL THEN mti.year
+        ELSE NULL
+    END AS child_directory_metadata_item_year
+    , %s as child_directory_metadata_item_guids_external
 FROM
     ls
     JOIN directories d ON d.parent_directory_id = ls.section_directory_id
     LEFT JOIN directories d2 ON d2.parent_directory_id = d.id
-    JOIN media_parts mdp ON mdp.directory_id = d.id OR mdp.directory_id = d2.id
+    JOIN media_parts mdp ON (mdp.directory_id = d.id OR mdp.directory_id = d2.id)
+        -- the location of the files, for libraries with multiple locations
+        AND substr(mdp.file, 1, length(rtrim(ls.section_root_path, '/')) + 1) = rtrim(ls.section_root_path, '/') || '/'
     JOIN media_items mdi ON mdi.id = mdp.media_item_id
     JOIN metadata_items mti ON mti.id = mdi.metadata_item_id
     LEFT JOIN metadata_items mti2 ON mti2.id = mti.parent_id
     LEFT JOIN metadata_items mti3 ON mti3.id = mti2.parent_id
-	LEFT JOIN taggings tj ON tj.metadata_item_id = (CASE WHEN mti3.guid IS NOT NULL THEN mti3.id WHEN mti2.guid IS NOT NULL THEN mti2.id ELSE mti.id END)
-    LEFT JOIN tags t ON t.id = tj.tag_id AND t.tag_type = 314
+%s`
+	sqlWhereLibraryItems = `
 WHERE
    ls.library_id = $1
 GROUP BY d.id
+`
+	sqlWhereSectionItem = `
+WHERE
+   ls.section_id = $1
+   AND d.path = $2
+GROUP BY d.id
+`
+	sqlSelectShowEpisodes = `
+SELECT DISTINCT
+    mdp.file,
+    season."index",
+    episode."index"
+FROM
+    metadata_items season
+    JOIN metadata_items episode ON episode.parent_id = season.id
+    JOIN media_items mdi ON mdi.metadata_item_id = episode.id
+    JOIN media_parts mdp ON mdp.media_item_id = mdi.id
+WHERE
+    season.parent_id = $1
+    AND episode.metadata_type = 4
+ORDER BY mdp.file, season."index", episode."index"
+`
+	sqlSelectMetadataGuid = `
+SELECT guid FROM metadata_items WHERE id = $1
+`
+	sqlSelectMediaItemsCount = `
+SELECT
+    COUNT(*)
+FROM
+    media_items mdi
+    JOIN metadata_items mti ON mti.id = mdi.metadata_item_id
+    LEFT JOIN metadata_items mti2 ON mti2.id = mti.parent_id
+WHERE
+    mti.id = $1
+    OR mti.parent_id = $1
+    OR mti2.parent_id = $1
+`
+	sqlSelectMediaPartExists = `
+SELECT EXISTS (SELECT 1 FROM media_parts WHERE file = $1)
+`
+	sqlSelectFolderMediaParts = `
+SELECT file FROM media_parts WHERE substr(file, 1, length($1) + 1) = $1 || '/'
 `
 )
@@ -0,0 +1,57 @@
+package plex
+
+import (
+	"context"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"strconv"
+)
+
+type sectionsResponse struct {
+	MediaContainer struct {
+		Directory []struct {
+			Key   string `json:"key"`
+			Title string `json:"title"`
+			UUID  string `json:"uuid"`
+		} `json:"Directory"`
+	} `json:"MediaContainer"`
+}
+
+// VerifyIdentity validates the database belongs to the server at the plex url by comparing library section uuids.
+// The database does not record the machine identifier of its server, so it cannot be compared.
+// Matching items of another server would change random items, as metadata ids are only unique per server.
+func (c *Client) VerifyIdentity(ctx context.Context) error {
+	// libraries read from the plex api belong to the server
+	if _, ok := c.store.(*apiStore); ok {
+		return nil
+	}
+
+	sections := new(sectionsResponse)
+	if err := c.getJson(ctx, nil, sections, "library", "sections"); err != nil {
+		return fmt.Errorf("could not retrieve library sections: %w", err)
+	}
+
+	// library section uuids are unique to a server
+	uuids := make(map[string]string)
+	for _, s := range sections.MediaContainer.Directory {
+		uuids[s.Key] = s.UUID
+	}
+
+	for _, lib := range c.libraries {
+		uuid, ok := uuids[strconv.Itoa(lib.ID)]
+		if !ok || uuid != lib.UUID {
+			return fmt.Errorf("database library %q (%v) not found on server %v: %w", lib.Name, lib.UUID,
+				c.url, plexarr.ErrServerMismatch)
+		}
+	}
+
+	if len(c.libraries) != len(uuids) {
+		return fmt.Errorf("database has %d libraries, server %v has %d: %w", len(c.libraries), c.url, len(uuids),
+			plexarr.ErrServerMismatch)
+	}
+
+	c.log.Debug().
+		Int("libraries", len(c.libraries)).
+		Msg("Verified server identity")
+	return nil
+}
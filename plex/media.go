@@ -1,33 +1,141 @@
 package plex
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/l3uddz/plexarr"
+	"path/filepath"
 	"strings"
 )
 
-func (c *Client) GetLibraryItems(libraryName string) ([]MediaItem, plexarr.LibraryType, error) {
+func (c *Client) GetLibraryItems(ctx context.Context, libraryName string) ([]MediaItem, *Library, error) {
 	// get library
 	lib, err := c.getLibraryByName(libraryName)
 	if err != nil {
-		return nil, 0, err
+		return nil, nil, err
 	}
 
 	// get library items
-	items, err := c.store.GetMediaItems(lib.ID)
+	items, err := c.store.GetMediaItems(ctx, lib.ID)
 	if err != nil {
-		return nil, 0, fmt.Errorf("retrieve library items: %v", err)
+		return nil, nil, fmt.Errorf("retrieve library items: %v", err)
 	}
 
-	return items, lib.Type, nil
+	return items, lib, nil
 }
 
-func (c *Client) getLibraryByName(name string) (*library, error) {
-	for _, lib := range c.libraries {
+// GetItemByPath returns the item and library of a top-level media folder, e.g. a movie or series folder.
+// With a normalizer, paths are compared once normalized, e.g. ignoring case.
+func (c *Client) GetItemByPath(ctx context.Context, libraryType plexarr.LibraryType, path string,
+	normalize plexarr.Normalizer) (*MediaItem, *Library, error) {
+	normalized := path
+	if normalize != nil {
+		normalized = normalize(path)
+	}
+
+	for i, lib := range c.libraries {
+		if lib.Type != libraryType {
+			continue
+		}
+
+		for _, loc := range lib.Locations {
+			root := loc.Path
+			if normalize != nil {
+				root = normalize(loc.Path)
+			}
+
+			// path belongs to this library location?
+			childPath, err := filepath.Rel(root, normalized)
+			if err != nil || childPath == "." || strings.HasPrefix(childPath, "..") {
+				continue
+			}
+
+			item, err := c.store.GetMediaItem(ctx, loc.ID, childPath)
+			if errors.Is(err, plexarr.ErrItemNotFound) && normalize != nil {
+				item, err = c.getNormalizedItem(ctx, lib.ID, loc, normalized, normalize)
+			}
+
+			switch {
+			case errors.Is(err, plexarr.ErrItemNotFound):
+				continue
+			case err != nil:
+				return nil, nil, fmt.Errorf("retrieve library item: %v", err)
+			}
+
+			return item, &c.libraries[i], nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%v: %w", path, plexarr.ErrItemNotFound)
+}
+
+// getNormalizedItem returns the item of a location whose normalized path is normalized, listing the library.
+func (c *Client) getNormalizedItem(ctx context.Context, libraryId int, loc Location, normalized string,
+	normalize plexarr.Normalizer) (*MediaItem, error) {
+	items, err := c.store.GetMediaItems(ctx, libraryId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		if item.Location.ID == loc.ID && normalize(item.Path) == normalized {
+			return &items[i], nil
+		}
+	}
+
+	return nil, plexarr.ErrItemNotFound
+}
+
+// GetEpisodeFiles returns the season and episode numbers plex attached to each file of a show.
+func (c *Client) GetEpisodeFiles(ctx context.Context, item MediaItem) (map[string][]plexarr.Episode, error) {
+	episodes, err := c.store.GetEpisodes(ctx, item.MetadataId)
+	if err != nil {
+		return nil, fmt.Errorf("retrieve episodes: %v", err)
+	}
+
+	return episodes, nil
+}
+
+// HasFile reports whether the file has been scanned into plex.
+// With a normalizer, the files within folder, e.g. the item folder, are compared once normalized.
+func (c *Client) HasFile(ctx context.Context, file string, folder string, normalize plexarr.Normalizer) (bool,
+	error) {
+	exists, err := c.store.HasMediaPart(ctx, file)
+	if err != nil || exists || normalize == nil {
+		return exists, err
+	}
+
+	files, err := c.store.GetMediaParts(ctx, folder)
+	if err != nil {
+		return false, err
+	}
+
+	normalized := normalize(file)
+	for _, f := range files {
+		if normalize(f) == normalized {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (c *Client) Libraries() []Library {
+	return c.libraries
+}
+
+func (c *Client) getLibraryByName(name string) (*Library, error) {
+	for i, lib := range c.libraries {
 		if strings.EqualFold(lib.Name, name) {
-			return &lib, nil
+			return &c.libraries[i], nil
 		}
 	}
 
 	return nil, fmt.Errorf("no library found with name: %v", name)
 }
+
+// UsesPlexAgent reports whether the library or item is matched with the new plex movie/tv agents.
+func UsesPlexAgent(lib *Library, item MediaItem) bool {
+	return strings.HasPrefix(lib.Agent, "tv.plex.agents.") || strings.HasPrefix(item.GUID, "plex://")
+}
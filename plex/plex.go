@@ -1,53 +1,126 @@
 package plex
 
 import (
+	"context"
 	"github.com/l3uddz/plexarr"
 	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+	"net/http"
+	"time"
 )
 
 type Config struct {
-	URL      string          `yaml:"url"`
-	Token    string          `yaml:"token"`
-	Database string          `yaml:"database"`
-	Rewrite  plexarr.Rewrite `yaml:"rewrite"`
+	URL      string              `yaml:"url"`
+	Token    string              `yaml:"token"`
+	Database string              `yaml:"database"`
+	Rewrite  plexarr.Rewrite     `yaml:"rewrite"`
+	HTTP     plexarr.HTTPConfig  `yaml:"http"`
+	Retry    plexarr.RetryConfig `yaml:"retry"`
+
+	// read library contents within a single read transaction
+	Snapshot bool `yaml:"snapshot"`
+
+	// changes
+	RateLimit     RateLimit     `yaml:"rate_limit"`
+	ChangeTimeout time.Duration `yaml:"change_timeout"`
 
 	Verbosity string `yaml:"verbosity"`
 }
 
+type RateLimit struct {
+	Interval time.Duration `yaml:"interval"`
+	Burst    int           `yaml:"burst"`
+}
+
 type Client struct {
 	url       string
 	token     string
-	libraries []library
+	libraries []Library
 
+	http  *http.Client
+	retry plexarr.RetryConfig
 	log   zerolog.Logger
-	store *datastore
+	store store
+
+	limiter       *rate.Limiter
+	changeTimeout time.Duration
+
+	snapshot bool
 }
 
-func New(c Config) (*Client, error) {
+// store reads library contents, from the plex database when configured, otherwise from the plex api.
+type store interface {
+	Libraries(ctx context.Context) ([]Library, error)
+	GetMediaItems(ctx context.Context, libraryId int) ([]MediaItem, error)
+	GetMediaItem(ctx context.Context, sectionId int, childPath string) (*MediaItem, error)
+	HasMediaPart(ctx context.Context, file string) (bool, error)
+	GetMediaParts(ctx context.Context, folder string) ([]string, error)
+	GetEpisodes(ctx context.Context, showMetadataId uint64) (map[string][]plexarr.Episode, error)
+	GetMetadataGuid(ctx context.Context, metadataItemId uint64) (string, error)
+	CountMediaItems(ctx context.Context, metadataItemId uint64) (int, error)
+}
 
-	store, err := newDatastore(c.Database)
+func New(c Config) (*Client, error) {
+	httpClient, err := plexarr.NewHTTPClient(c.HTTP)
 	if err != nil {
 		return nil, err
 	}
 
-	libraries, err := store.Libraries()
-	if err != nil {
-		return nil, err
+	if c.RateLimit.Interval <= 0 {
+		c.RateLimit.Interval = time.Second
+	}
+
+	if c.RateLimit.Burst <= 0 {
+		c.RateLimit.Burst = 1
+	}
+
+	if c.ChangeTimeout <= 0 {
+		c.ChangeTimeout = 2 * time.Minute
 	}
 
 	l := plexarr.GetLogger(c.Verbosity).With().
 		Str("url", c.URL).Logger()
 
+	client := &Client{
+		url:   c.URL,
+		token: c.Token,
+
+		http:  httpClient,
+		retry: c.Retry,
+		log:   l,
+
+		limiter:       rate.NewLimiter(rate.Every(c.RateLimit.Interval), c.RateLimit.Burst),
+		changeTimeout: c.ChangeTimeout,
+
+		snapshot: c.Snapshot,
+	}
+
+	// library contents are read over http when the database is not reachable
+	if c.Database != "" {
+		d, err := newDatastore(c.Database)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := client.checkSchema(context.Background(), d); err != nil {
+			return nil, err
+		}
+
+		client.store = d
+	} else {
+		client.store = &apiStore{client: client}
+		l.Debug().Msg("No database configured, reading libraries from the plex api")
+	}
+
+	libraries, err := client.store.Libraries(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
 	l.Debug().
 		Interface("libraries", libraries).
 		Msg("Retrieved libraries")
 
-	return &Client{
-		url:       c.URL,
-		token:     c.Token,
-		libraries: libraries,
-
-		log:   l,
-		store: store,
-	}, nil
+	client.libraries = libraries
+	return client, nil
 }
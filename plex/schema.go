@@ -0,0 +1,361 @@
+package plex
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// schema describes the layout of the plex database, library contents are read with the queries matching it.
+type schema struct {
+	// latest schema migration, 0 when unknown
+	version int64
+
+	// media parts reference their directory, otherwise items are derived from their files
+	directories bool
+	// external guids of items matched with the plex agents are stored as tags
+	externalGuids bool
+
+	sqlSelectLibraryItems string
+	sqlSelectSectionItem  string
+}
+
+// schemaVersions are the ranges of schema migrations (yyyymmddhhmm) plexarr is known to be compatible with.
+// Versions outside them are still read when their columns are present, with a warning.
+var schemaVersions = []struct {
+	from int64
+	to   int64
+}{
+	{from: 201801010000, to: 202112312359},
+}
+
+// schemaColumns are the columns every query relies on.
+var schemaColumns = map[string][]string{
+	"library_sections":  {"id", "name", "section_type", "agent", "uuid"},
+	"section_locations": {"id", "library_section_id", "root_path"},
+	"metadata_items":    {"id", "library_section_id", "parent_id", "metadata_type", "guid", "title", "year", "index"},
+	"media_items":       {"id", "metadata_item_id"},
+	"media_parts":       {"media_item_id", "file"},
+}
+
+// checkSchema detects the layout of the database, refusing databases it cannot read library contents from.
+func (c *Client) checkSchema(ctx context.Context, d *datastore) error {
+	s, err := d.detectSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	l := c.log.With().
+		Int64("schema_version", s.version).
+		Bool("directories", s.directories).
+		Bool("external_guids", s.externalGuids).
+		Logger()
+
+	switch {
+	case s.version == 0:
+		l.Warn().Msg("Unknown plex database schema version, library contents may be incomplete")
+	case !knownSchemaVersion(s.version):
+		l.Warn().Msg("Plex database schema version has not been verified, library contents may be incomplete")
+	default:
+		l.Debug().Msg("Detected plex database schema")
+	}
+
+	if !s.directories {
+		l.Warn().Msg("Plex database has no directory hierarchy, deriving library items from their files")
+	}
+
+	d.schema = s
+	return nil
+}
+
+func knownSchemaVersion(version int64) bool {
+	for _, r := range schemaVersions {
+		if version >= r.from && version <= r.to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *datastore) detectSchema(ctx context.Context) (schema, error) {
+	s := schema{}
+
+	version, err := d.schemaVersion(ctx)
+	if err != nil {
+		return s, err
+	}
+	s.version = version
+
+	// required tables
+	tables := make([]string, 0, len(schemaColumns))
+	for table := range schemaColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		missing, err := d.missingColumns(ctx, table, schemaColumns[table]...)
+		if err != nil {
+			return s, err
+		}
+
+		if len(missing) > 0 {
+			return s, fmt.Errorf("%v misses columns %v (schema version %d): %w", table,
+				strings.Join(missing, ", "), s.version, plexarr.ErrUnsupportedSchema)
+		}
+	}
+
+	// optional layouts
+	s.directories, err = d.hasColumns(ctx, map[string][]string{
+		"directories": {"id", "library_section_id", "parent_directory_id", "path"},
+		"media_parts": {"directory_id"},
+	})
+	if err != nil {
+		return s, err
+	}
+
+	s.externalGuids, err = d.hasColumns(ctx, map[string][]string{
+		"taggings": {"metadata_item_id", "tag_id"},
+		"tags":     {"id", "tag", "tag_type"},
+	})
+	if err != nil {
+		return s, err
+	}
+
+	// queries
+	externalGuids := [2]string{sqlSelectNoExternalGuids, ""}
+	if s.externalGuids {
+		externalGuids = [2]string{sqlSelectExternalGuids, sqlJoinExternalGuids}
+	}
+
+	if s.directories {
+		query := fmt.Sprintf(sqlSelectItemsMetadata, externalGuids[0], externalGuids[1])
+		s.sqlSelectLibraryItems = query + sqlWhereLibraryItems
+		s.sqlSelectSectionItem = query + sqlWhereSectionItem
+	} else {
+		query := fmt.Sprintf(sqlSelectFilesMetadata, externalGuids[0], externalGuids[1])
+		s.sqlSelectLibraryItems = query + sqlWhereLibraryFiles
+		s.sqlSelectSectionItem = query + sqlWhereSectionFiles
+	}
+
+	return s, nil
+}
+
+// schemaVersion returns the latest schema migration of the database.
+func (d *datastore) schemaVersion(ctx context.Context) (int64, error) {
+	missing, err := d.missingColumns(ctx, "schema_migrations", "version")
+	if err != nil || len(missing) > 0 {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := d.db.QueryRowContext(ctx, sqlSelectSchemaVersion).Scan(&version); err != nil {
+		return 0, fmt.Errorf("select schema version: %v", err)
+	}
+
+	// some migrations include seconds
+	v := version.Int64
+	for v > 999999999999 {
+		v /= 100
+	}
+
+	return v, nil
+}
+
+func (d *datastore) hasColumns(ctx context.Context, columns map[string][]string) (bool, error) {
+	for table, names := range columns {
+		missing, err := d.missingColumns(ctx, table, names...)
+		if err != nil {
+			return false, err
+		}
+
+		if len(missing) > 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (d *datastore) missingColumns(ctx context.Context, table string, names ...string) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, sqlSelectTableColumns, table)
+	if err != nil {
+		return nil, fmt.Errorf("select %v columns: %v", table, err)
+	}
+
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan %v column row: %v", table, err)
+		}
+
+		columns[name] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate %v column rows: %v", table, err)
+	}
+
+	missing := make([]string, 0)
+	for _, name := range names {
+		if !columns[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing, nil
+}
+
+// scanMediaFiles returns an item for every top-level folder of a section, from the files in it.
+func scanMediaFiles(rows *sql.Rows) ([]MediaItem, error) {
+	seen := make(map[string]bool)
+	mediaItems := make([]MediaItem, 0)
+	for rows.Next() {
+		m := new(struct {
+			LibraryId         *uint64
+			SectionId         *uint64
+			SectionPath       *string
+			File              *string
+			MetadataItemId    *uint64
+			MetadataItemGuid  *string
+			MetadataItemTitle *string
+			MetadataItemYear  *int
+			ExternalGuids     *string
+		})
+		if err := rows.Scan(&m.LibraryId, &m.SectionId, &m.SectionPath, &m.File, &m.MetadataItemId, &m.MetadataItemGuid,
+			&m.MetadataItemTitle, &m.MetadataItemYear, &m.ExternalGuids); err != nil {
+			return nil, fmt.Errorf("scan media file row: %v", err)
+		}
+
+		if m.LibraryId == nil || m.SectionId == nil || m.SectionPath == nil || m.File == nil ||
+			m.MetadataItemId == nil || m.MetadataItemGuid == nil {
+			return nil, fmt.Errorf("invalid media file row: %+v", m)
+		}
+
+		// files directly in the section are not part of a media folder
+		childPath, err := filepath.Rel(*m.SectionPath, *m.File)
+		parts := strings.SplitN(childPath, string(filepath.Separator), 2)
+		if err != nil || strings.HasPrefix(childPath, "..") || len(parts) < 2 {
+			continue
+		}
+
+		path := filepath.Join(*m.SectionPath, parts[0])
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		externalGuids := make([]string, 0)
+		if m.ExternalGuids != nil {
+			for _, guid := range strings.Split(*m.ExternalGuids, ",") {
+				if guid != "" {
+					externalGuids = append(externalGuids, guid)
+				}
+			}
+		}
+
+		title := ""
+		if m.MetadataItemTitle != nil {
+			title = *m.MetadataItemTitle
+		}
+
+		year := 0
+		if m.MetadataItemYear != nil {
+			year = *m.MetadataItemYear
+		}
+
+		mediaItems = append(mediaItems, MediaItem{
+			LibraryId:     *m.LibraryId,
+			Location:      Location{ID: int(*m.SectionId), Path: *m.SectionPath},
+			Path:          path,
+			MetadataId:    *m.MetadataItemId,
+			GUID:          *m.MetadataItemGuid,
+			ExternalGUIDs: externalGuids,
+			Title:         title,
+			Year:          year,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate media file rows: %v", err)
+	}
+
+	return mediaItems, nil
+}
+
+//goland:noinspection ALL
+const (
+	sqlSelectSchemaVersion = `
+SELECT MAX(CAST(version AS INTEGER)) FROM schema_migrations
+`
+	sqlSelectTableColumns = `
+SELECT name FROM pragma_table_info($1)
+`
+	sqlSelectExternalGuids   = `GROUP_CONCAT(DISTINCT t.tag)`
+	sqlSelectNoExternalGuids = `NULL`
+	sqlJoinExternalGuids     = `
+    LEFT JOIN taggings tj ON tj.metadata_item_id = (CASE WHEN mti3.guid IS NOT NULL THEN mti3.id WHEN mti2.guid IS NOT NULL THEN mti2.id ELSE mti.id END)
+    LEFT JOIN tags t ON t.id = tj.tag_id AND t.tag_type = 314
+`
+	sqlSelectFilesMetadata = `
+SELECT
+    ls.id AS library_id,
+    sl.id AS section_id,
+    sl.root_path AS section_root_path,
+    mdp.file,
+    CASE
+        WHEN mti3.guid IS NOT NULL THEN mti3.id
+        WHEN mti2.guid IS NOT NULL THEN mti2.id
+        WHEN mti.guid IS NOT NULL THEN mti.id
+        ELSE NULL
+    END AS metadata_item_id,
+    CASE
+        WHEN mti3.guid IS NOT NULL THEN mti3.guid
+        WHEN mti2.guid IS NOT NULL THEN mti2.guid
+        WHEN mti.guid IS NOT NULL THEN mti.guid
+        ELSE NULL
+    END AS metadata_item_guid,
+    CASE
+        WHEN mti3.guid IS NOT NULL THEN mti3.title
+        WHEN mti2.guid IS NOT NULL THEN mti2.title
+        WHEN mti.guid IS NOT NULL THEN mti.title
+        ELSE NULL
+    END AS metadata_item_title,
+    CASE
+        WHEN mti3.guid IS NOT NULL THEN mti3.year
+        WHEN mti2.guid IS NOT NULL THEN mti2.year
+        WHEN mti.guid IS NOT NULL THEN mti.year
+        ELSE NULL
+    END AS metadata_item_year,
+    %s AS metadata_item_guids_external
+FROM
+    library_sections ls
+    JOIN section_locations sl ON sl.library_section_id = ls.id
+    JOIN metadata_items mti ON mti.library_section_id = ls.id
+    JOIN media_items mdi ON mdi.metadata_item_id = mti.id
+    JOIN media_parts mdp ON mdp.media_item_id = mdi.id
+    LEFT JOIN metadata_items mti2 ON mti2.id = mti.parent_id
+    LEFT JOIN metadata_items mti3 ON mti3.id = mti2.parent_id
+%s`
+	sqlWhereLibraryFiles = `
+WHERE
+    ls.id = $1
+GROUP BY sl.id, mdp.id
+ORDER BY sl.id, mdp.file
+`
+	sqlWhereSectionFiles = `
+WHERE
+    sl.id = $1
+    AND substr(mdp.file, 1, length(sl.root_path) + length($2) + 2) = sl.root_path || '/' || $2 || '/'
+GROUP BY sl.id, mdp.id
+ORDER BY sl.id, mdp.file
+`
+)
@@ -0,0 +1,82 @@
+package plex
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Snapshot returns a client reading library contents from a consistent view of the database, when snapshots are
+// enabled. Otherwise, the client itself is returned. Release the snapshot once its library contents have been read.
+func (c *Client) Snapshot(ctx context.Context) (*Client, error) {
+	d, ok := c.store.(*datastore)
+	if !ok || !c.snapshot {
+		return c, nil
+	}
+
+	start := time.Now()
+	snapshot, err := d.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot database: %w", err)
+	}
+
+	c.log.Debug().
+		Dur("duration", time.Since(start)).
+		Msg("Started database snapshot")
+
+	view := *c
+	view.store = snapshot
+	return &view, nil
+}
+
+// Release ends the read transaction of a snapshot, it does nothing for other clients.
+func (c *Client) Release() {
+	d, ok := c.store.(*datastore)
+	if !ok || d.conn == nil {
+		return
+	}
+
+	// the transaction only read, rolling it back ends it
+	if _, err := d.conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+		c.log.Warn().
+			Err(err).
+			Msg("Failed ending database snapshot")
+	}
+
+	if err := d.conn.Close(); err != nil {
+		c.log.Warn().
+			Err(err).
+			Msg("Failed closing database snapshot")
+	}
+}
+
+// begin starts a read transaction on a single connection, every read of the returned store sees the database as it
+// was when the transaction started, including changes plex has not yet checkpointed from its wal.
+func (d *datastore) begin(ctx context.Context) (*datastore, error) {
+	db, ok := d.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("database is already a snapshot")
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not open database connection: %v", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("begin read transaction: %v", err)
+	}
+
+	// sqlite defers the snapshot to the first read of a transaction
+	var count int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&count); err != nil {
+		_, _ = conn.ExecContext(ctx, "ROLLBACK")
+		_ = conn.Close()
+		return nil, fmt.Errorf("begin read transaction: %v", err)
+	}
+
+	return &datastore{db: conn, schema: d.schema, conn: conn}, nil
+}
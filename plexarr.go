@@ -1,16 +1,61 @@
 package plexarr
 
 import (
+	"context"
+	"fmt"
 	"github.com/pkg/errors"
-	"regexp"
+	"io"
 )
 
 type Pvr interface {
-	GetLibraryItems() (map[string]PvrItem, error)
+	GetLibraryItems(ctx context.Context) (*PvrLibrary, error)
+}
+
+type PvrLibrary struct {
+	// Items by rewritten path
+	Items map[string]PvrItem
+	// Skipped items, their path is not unique
+	Skipped []PvrItem
+}
+
+// WebhookPvr is implemented by pvrs that can decode their webhook payloads.
+type WebhookPvr interface {
+	Pvr
+	ParseWebhook(r io.Reader) (*WebhookEvent, error)
+}
+
+// EpisodePvr is implemented by pvrs that can list the episode files of an item.
+type EpisodePvr interface {
+	Pvr
+	GetEpisodeFiles(ctx context.Context, item PvrItem) ([]EpisodeFile, error)
+}
+
+type EpisodeFile struct {
+	Path     string
+	PvrPath  string
+	Episodes []Episode
+}
+
+type Episode struct {
+	Season  int
+	Episode int
+}
+
+func (e Episode) String() string {
+	return fmt.Sprintf("S%02dE%02d", e.Season, e.Episode)
+}
+
+type WebhookEvent struct {
+	Type string
+	Item PvrItem
+	File string
 }
 
 type PvrItem struct {
+	Pvr     string
+	ID      uint64
 	Title   string
+	Year    int
 	Path    string
 	PvrPath string
 	GUID    []string
@@ -21,40 +66,50 @@ type LibraryType int
 var (
 	MovieLibrary LibraryType = 1
 	TvLibrary    LibraryType = 2
+	MusicLibrary LibraryType = 8
 )
 
+func (t LibraryType) String() string {
+	switch t {
+	case MovieLibrary:
+		return "movie"
+	case TvLibrary:
+		return "show"
+	case MusicLibrary:
+		return "artist"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(t))
+	}
+}
+
 var (
 	// ErrPlexUnavailable may occur when a plex api cannot be validated
 	ErrPlexUnavailable = errors.New("plex unavailable")
 
+	// ErrPvrUnavailable may occur when a pvr api cannot be reached
+	ErrPvrUnavailable = errors.New("pvr unavailable")
+
 	// ErrFatal indicates a severe problem related to development.
 	ErrFatal = errors.New("fatal development related error")
-)
 
-type Rewrite struct {
-	From string `yaml:"from"`
-	To   string `yaml:"to"`
-}
+	// ErrItemNotFound may occur when an item is not (yet) known to plex
+	ErrItemNotFound = errors.New("item not found")
 
-type Rewriter func(string) string
+	// ErrServerMismatch indicates the plex database belongs to another server than the plex url
+	ErrServerMismatch = errors.New("plex database does not belong to the plex server")
 
-func NewRewriter(r Rewrite) (Rewriter, error) {
-	if r.From == "" || r.To == "" {
-		rewriter := func(input string) string {
-			return input
-		}
+	// ErrChangeTimeout may occur when plex has not completed a change in time
+	ErrChangeTimeout = errors.New("timed out waiting for change to complete")
 
-		return rewriter, nil
-	}
+	// ErrUnsupportedSchema indicates the plex database layout is not known
+	ErrUnsupportedSchema = errors.New("unsupported plex database schema")
 
-	re, err := regexp.Compile(r.From)
-	if err != nil {
-		return nil, err
-	}
+	// ErrNoConfidentMatch indicates plex returned no match that certainly belongs to the pvr item
+	ErrNoConfidentMatch = errors.New("no confident plex match")
 
-	rewriter := func(input string) string {
-		return re.ReplaceAllString(input, r.To)
-	}
+	// ErrUnconfirmedChange may occur when a change request failed in transit, plex may have applied it
+	ErrUnconfirmedChange = errors.New("plex change unconfirmed")
 
-	return rewriter, nil
-}
+	// ErrUnmatchable indicates a pvr item cannot be matched, e.g. it has no guid plex can search for
+	ErrUnmatchable = errors.New("pvr item cannot be matched")
+)
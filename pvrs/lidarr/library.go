@@ -0,0 +1,141 @@
+package lidarr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"net/http"
+)
+
+type artistItem struct {
+	ArtistName      string `json:"artistName"`
+	Path            string `json:"path"`
+	ForeignArtistId string `json:"foreignArtistId"`
+	Statistics      struct {
+		SizeOnDisk uint64 `json:"sizeOnDisk"`
+	} `json:"statistics"`
+	Status string `json:"status"`
+}
+
+func (c *Client) GetLibraryItems(ctx context.Context) (*plexarr.PvrLibrary, error) {
+	// retrieve items
+	var lidarrItems []artistItem
+	err := c.retry.DoRead(ctx, c.log, func() error {
+		items, err := c.getArtists(ctx)
+		lidarrItems = items
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// create response
+	skipNonUniqueItems := make(map[string]int)
+	pvrItems := make(map[string]plexarr.PvrItem)
+	skippedItems := make([]plexarr.PvrItem, 0)
+	for _, item := range lidarrItems {
+		// skip item if we do not have a file or its marked as deleted
+		if item.Statistics.SizeOnDisk == 0 || item.Status == "deleted" {
+			continue
+		}
+
+		// create guids
+		guids := artistGuids(item)
+		if len(guids) == 0 {
+			c.log.Warn().
+				Interface("artist", item).
+				Msg("Failed creating at-least one plex guid, skipping item")
+			continue
+		}
+
+		// rewrite path
+		rewritePath := c.rewrite(item.Path)
+		pvrItem := plexarr.PvrItem{
+			Pvr:     c.name,
+			Title:   item.ArtistName,
+			Path:    rewritePath,
+			PvrPath: item.Path,
+			GUID:    guids,
+		}
+
+		// skip this item?
+		if skips, ok := skipNonUniqueItems[rewritePath]; ok {
+			// this item should be skipped
+			c.log.Warn().
+				Interface("artist", item).
+				Str("rewrite_path", rewritePath).
+				Str("pvr_path", item.Path).
+				Int("path_duplicates", skips).
+				Msg("Path is not unique, skipping item(s)")
+
+			skipNonUniqueItems[rewritePath]++
+			skippedItems = append(skippedItems, pvrItem)
+			continue
+		}
+
+		// item path exists in map?
+		if existing, ok := pvrItems[rewritePath]; ok {
+			c.log.Warn().
+				Interface("artist", item).
+				Msg("Path is not unique, skipping item(s)")
+
+			skipNonUniqueItems[rewritePath] = 2
+			skippedItems = append(skippedItems, existing, pvrItem)
+			delete(pvrItems, rewritePath)
+			continue
+		}
+
+		// add item
+		pvrItems[rewritePath] = pvrItem
+	}
+
+	return &plexarr.PvrLibrary{
+		Items:   pvrItems,
+		Skipped: skippedItems,
+	}, nil
+}
+
+func (c *Client) getArtists(ctx context.Context) ([]artistItem, error) {
+	// create request
+	req, err := http.NewRequestWithContext(ctx, "GET", plexarr.JoinURL(c.url, "api", "v1", "artist"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
+	}
+
+	// set headers
+	req.Header.Set("X-Api-Key", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	// send request
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving lidarr library: %v: %w", err, plexarr.ErrPvrUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	// validate response
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed validating lidarr library response: %v: %w", res.StatusCode,
+			plexarr.StatusError(res.StatusCode, plexarr.ErrPvrUnavailable))
+	}
+
+	// decode response
+	lidarrItems := make([]artistItem, 0)
+	if err := json.NewDecoder(res.Body).Decode(&lidarrItems); err != nil {
+		return nil, fmt.Errorf("failed decoding lidarr library response: %w", err)
+	}
+
+	return lidarrItems, nil
+}
+
+func artistGuids(item artistItem) []string {
+	guids := make([]string, 0)
+
+	if item.ForeignArtistId != "" {
+		guids = append(guids, fmt.Sprintf("com.plexapp.agents.mbid://%s", item.ForeignArtistId))
+	}
+
+	return guids
+}
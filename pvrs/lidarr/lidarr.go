@@ -0,0 +1,58 @@
+package lidarr
+
+import (
+	"github.com/l3uddz/plexarr"
+	"github.com/rs/zerolog"
+	"net/http"
+)
+
+type Config struct {
+	Name   string `yaml:"name"`
+	URL    string `yaml:"url"`
+	ApiKey string `yaml:"api_key"`
+
+	Verbosity string              `yaml:"verbosity"`
+	Rewrite   plexarr.Rewrite     `yaml:"rewrite"`
+	HTTP      plexarr.HTTPConfig  `yaml:"http"`
+	Retry     plexarr.RetryConfig `yaml:"retry"`
+
+	// providers whose guid plex items are matched with, in order of preference
+	GuidPreference []string `yaml:"guid_preference"`
+}
+
+type Client struct {
+	name  string
+	url   string
+	token string
+
+	http    *http.Client
+	retry   plexarr.RetryConfig
+	log     zerolog.Logger
+	rewrite plexarr.Rewriter
+}
+
+func New(c Config) (*Client, error) {
+	rewriter, err := plexarr.NewRewriter(c.Rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := plexarr.NewHTTPClient(c.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	l := plexarr.GetLogger(c.Verbosity).With().
+		Str("pvr", c.Name).
+		Str("url", c.URL).Logger()
+
+	return &Client{
+		name:    c.Name,
+		url:     c.URL,
+		token:   c.ApiKey,
+		http:    httpClient,
+		retry:   c.Retry,
+		log:     l,
+		rewrite: rewriter,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package radarr
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/l3uddz/plexarr"
@@ -9,6 +10,7 @@ import (
 
 type movieItem struct {
 	Title      string  `json:"title"`
+	Year       int     `json:"year"`
 	Path       string  `json:"path"`
 	ImdbId     *string `json:"imdbId"`
 	TmdbId     *uint64 `json:"tmdbId"`
@@ -17,39 +19,22 @@ type movieItem struct {
 	Status     string  `json:"status"`
 }
 
-func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
-	// create request
-	req, err := http.NewRequest("GET", plexarr.JoinURL(c.url, "api", "v3", "movie"), nil)
-	if err != nil {
-		return nil, fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
-	}
-
-	// set headers
-	req.Header.Set("X-Api-Key", c.token)
-	req.Header.Set("Accept", "application/json")
-
-	// send request
-	res, err := http.DefaultClient.Do(req)
+func (c *Client) GetLibraryItems(ctx context.Context) (*plexarr.PvrLibrary, error) {
+	// retrieve items
+	var radarrItems []movieItem
+	err := c.retry.DoRead(ctx, c.log, func() error {
+		items, err := c.getMovies(ctx)
+		radarrItems = items
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed retrieving radarr library: %w", err)
-	}
-
-	defer res.Body.Close()
-
-	// validate response
-	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("failed validating radarr library response: %v", res.StatusCode)
-	}
-
-	// decode response
-	radarrItems := make([]movieItem, 0)
-	if err := json.NewDecoder(res.Body).Decode(&radarrItems); err != nil {
-		return nil, fmt.Errorf("failed decoding radarr library response: %w", err)
+		return nil, err
 	}
 
 	// create response
 	skipNonUniqueItems := make(map[string]int)
 	pvrItems := make(map[string]plexarr.PvrItem)
+	skippedItems := make([]plexarr.PvrItem, 0)
 	for _, item := range radarrItems {
 		// skip item if we do not have a file or its marked as deleted
 		if !item.HasFile || item.Status == "deleted" {
@@ -57,17 +42,7 @@ func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
 		}
 
 		// create guids
-		guids := make([]string, 0)
-
-		if item.ImdbId != nil && *item.ImdbId != "" {
-			guids = append(guids, fmt.Sprintf("com.plexapp.agents.imdb://%s", *item.ImdbId))
-		}
-
-		if item.TmdbId != nil && *item.TmdbId != 0 {
-			guids = append(guids, fmt.Sprintf("com.plexapp.agents.themoviedb://%d", *item.TmdbId))
-			guids = append(guids, fmt.Sprintf("com.plexapp.agents.tmdb://%d", *item.TmdbId))
-		}
-
+		guids := movieGuids(item)
 		if len(guids) == 0 {
 			c.log.Warn().
 				Interface("movie", item).
@@ -77,6 +52,14 @@ func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
 
 		// rewrite path
 		rewritePath := c.rewrite(item.Path)
+		pvrItem := plexarr.PvrItem{
+			Pvr:     c.name,
+			Title:   item.Title,
+			Year:    item.Year,
+			Path:    rewritePath,
+			PvrPath: item.Path,
+			GUID:    guids,
+		}
 
 		// skip this item?
 		if skips, ok := skipNonUniqueItems[rewritePath]; ok {
@@ -89,28 +72,77 @@ func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
 				Msg("Path is not unique, skipping item(s)")
 
 			skipNonUniqueItems[rewritePath]++
+			skippedItems = append(skippedItems, pvrItem)
 			continue
 		}
 
 		// item path exists in map?
-		if _, ok := pvrItems[rewritePath]; ok {
+		if existing, ok := pvrItems[rewritePath]; ok {
 			c.log.Warn().
 				Interface("movie", item).
 				Msg("Path is not unique, skipping item(s)")
 
 			skipNonUniqueItems[rewritePath] = 2
+			skippedItems = append(skippedItems, existing, pvrItem)
 			delete(pvrItems, rewritePath)
 			continue
 		}
 
 		// add item
-		pvrItems[rewritePath] = plexarr.PvrItem{
-			Title:   item.Title,
-			Path:    rewritePath,
-			PvrPath: item.Path,
-			GUID:    guids,
-		}
+		pvrItems[rewritePath] = pvrItem
+	}
+
+	return &plexarr.PvrLibrary{
+		Items:   pvrItems,
+		Skipped: skippedItems,
+	}, nil
+}
+
+func (c *Client) getMovies(ctx context.Context) ([]movieItem, error) {
+	// create request
+	req, err := http.NewRequestWithContext(ctx, "GET", plexarr.JoinURL(c.url, "api", "v3", "movie"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
+	}
+
+	// set headers
+	req.Header.Set("X-Api-Key", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	// send request
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving radarr library: %v: %w", err, plexarr.ErrPvrUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	// validate response
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed validating radarr library response: %v: %w", res.StatusCode,
+			plexarr.StatusError(res.StatusCode, plexarr.ErrPvrUnavailable))
+	}
+
+	// decode response
+	radarrItems := make([]movieItem, 0)
+	if err := json.NewDecoder(res.Body).Decode(&radarrItems); err != nil {
+		return nil, fmt.Errorf("failed decoding radarr library response: %w", err)
+	}
+
+	return radarrItems, nil
+}
+
+func movieGuids(item movieItem) []string {
+	guids := make([]string, 0)
+
+	if item.ImdbId != nil && *item.ImdbId != "" {
+		guids = append(guids, fmt.Sprintf("com.plexapp.agents.imdb://%s", *item.ImdbId))
+	}
+
+	if item.TmdbId != nil && *item.TmdbId != 0 {
+		guids = append(guids, fmt.Sprintf("com.plexapp.agents.themoviedb://%d", *item.TmdbId))
+		guids = append(guids, fmt.Sprintf("com.plexapp.agents.tmdb://%d", *item.TmdbId))
 	}
 
-	return pvrItems, nil
+	return guids
 }
@@ -3,6 +3,7 @@ package radarr
 import (
 	"github.com/l3uddz/plexarr"
 	"github.com/rs/zerolog"
+	"net/http"
 )
 
 type Config struct {
@@ -10,14 +11,22 @@ type Config struct {
 	URL    string `yaml:"url"`
 	ApiKey string `yaml:"api_key"`
 
-	Verbosity string          `yaml:"verbosity"`
-	Rewrite   plexarr.Rewrite `yaml:"rewrite"`
+	Verbosity string              `yaml:"verbosity"`
+	Rewrite   plexarr.Rewrite     `yaml:"rewrite"`
+	HTTP      plexarr.HTTPConfig  `yaml:"http"`
+	Retry     plexarr.RetryConfig `yaml:"retry"`
+
+	// providers whose guid plex items are matched with, in order of preference
+	GuidPreference []string `yaml:"guid_preference"`
 }
 
 type Client struct {
+	name  string
 	url   string
 	token string
 
+	http    *http.Client
+	retry   plexarr.RetryConfig
 	log     zerolog.Logger
 	rewrite plexarr.Rewriter
 }
@@ -28,13 +37,21 @@ func New(c Config) (*Client, error) {
 		return nil, err
 	}
 
+	httpClient, err := plexarr.NewHTTPClient(c.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
 	l := plexarr.GetLogger(c.Verbosity).With().
 		Str("pvr", c.Name).
 		Str("url", c.URL).Logger()
 
 	return &Client{
+		name:    c.Name,
 		url:     c.URL,
 		token:   c.ApiKey,
+		http:    httpClient,
+		retry:   c.Retry,
 		log:     l,
 		rewrite: rewriter,
 	}, nil
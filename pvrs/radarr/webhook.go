@@ -0,0 +1,78 @@
+package radarr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"io"
+	"path"
+)
+
+type webhookPayload struct {
+	EventType string `json:"eventType"`
+	Movie     struct {
+		Title      string  `json:"title"`
+		FolderPath string  `json:"folderPath"`
+		ImdbId     *string `json:"imdbId"`
+		TmdbId     *uint64 `json:"tmdbId"`
+	} `json:"movie"`
+	MovieFile *struct {
+		Path         string `json:"path"`
+		RelativePath string `json:"relativePath"`
+	} `json:"movieFile"`
+}
+
+func (c *Client) ParseWebhook(r io.Reader) (*plexarr.WebhookEvent, error) {
+	// decode payload
+	payload := new(webhookPayload)
+	if err := json.NewDecoder(r).Decode(payload); err != nil {
+		return nil, fmt.Errorf("failed decoding radarr webhook: %w", err)
+	}
+
+	// ignore events that do not change files
+	switch payload.EventType {
+	case "Download", "Rename":
+	default:
+		return nil, nil
+	}
+
+	item := movieItem{
+		Title:  payload.Movie.Title,
+		Path:   payload.Movie.FolderPath,
+		ImdbId: payload.Movie.ImdbId,
+		TmdbId: payload.Movie.TmdbId,
+	}
+
+	if item.Path == "" {
+		return nil, errors.New("radarr webhook is missing movie folder path")
+	}
+
+	guids := movieGuids(item)
+	if len(guids) == 0 {
+		return nil, fmt.Errorf("failed creating at-least one plex guid for radarr webhook: %v", item.Title)
+	}
+
+	// determine imported file
+	file := ""
+	if payload.MovieFile != nil {
+		switch {
+		case payload.MovieFile.Path != "":
+			file = c.rewrite(payload.MovieFile.Path)
+		case payload.MovieFile.RelativePath != "":
+			file = c.rewrite(path.Join(item.Path, payload.MovieFile.RelativePath))
+		}
+	}
+
+	return &plexarr.WebhookEvent{
+		Type: payload.EventType,
+		Item: plexarr.PvrItem{
+			Pvr:     c.name,
+			Title:   item.Title,
+			Path:    c.rewrite(item.Path),
+			PvrPath: item.Path,
+			GUID:    guids,
+		},
+		File: file,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package sonarr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+type episodeItem struct {
+	SeasonNumber  int    `json:"seasonNumber"`
+	EpisodeNumber int    `json:"episodeNumber"`
+	EpisodeFileId uint64 `json:"episodeFileId"`
+	HasFile       bool   `json:"hasFile"`
+}
+
+type episodeFileItem struct {
+	Id   uint64 `json:"id"`
+	Path string `json:"path"`
+}
+
+func (c *Client) GetEpisodeFiles(ctx context.Context, item plexarr.PvrItem) ([]plexarr.EpisodeFile, error) {
+	query := url.Values{}
+	query.Set("seriesId", strconv.FormatUint(item.ID, 10))
+
+	// retrieve episode files
+	files := make([]episodeFileItem, 0)
+	if err := c.getJson(ctx, query, &files, "api", "v3", "episodefile"); err != nil {
+		return nil, fmt.Errorf("failed retrieving sonarr episode files: %w", err)
+	}
+
+	// retrieve episodes
+	episodes := make([]episodeItem, 0)
+	if err := c.getJson(ctx, query, &episodes, "api", "v3", "episode"); err != nil {
+		return nil, fmt.Errorf("failed retrieving sonarr episodes: %w", err)
+	}
+
+	fileEpisodes := make(map[uint64][]plexarr.Episode)
+	for _, episode := range episodes {
+		if !episode.HasFile || episode.EpisodeFileId == 0 {
+			continue
+		}
+
+		fileEpisodes[episode.EpisodeFileId] = append(fileEpisodes[episode.EpisodeFileId], plexarr.Episode{
+			Season:  episode.SeasonNumber,
+			Episode: episode.EpisodeNumber,
+		})
+	}
+
+	// create response
+	episodeFiles := make([]plexarr.EpisodeFile, 0, len(files))
+	for _, file := range files {
+		eps, ok := fileEpisodes[file.Id]
+		if !ok {
+			continue
+		}
+
+		sort.Slice(eps, func(i, j int) bool {
+			if eps[i].Season != eps[j].Season {
+				return eps[i].Season < eps[j].Season
+			}
+			return eps[i].Episode < eps[j].Episode
+		})
+
+		episodeFiles = append(episodeFiles, plexarr.EpisodeFile{
+			Path:     c.rewrite(file.Path),
+			PvrPath:  file.Path,
+			Episodes: eps,
+		})
+	}
+
+	return episodeFiles, nil
+}
+
+func (c *Client) getJson(ctx context.Context, query url.Values, v interface{}, path ...string) error {
+	return c.retry.DoRead(ctx, c.log, func() error {
+		return c.fetchJson(ctx, query, v, path...)
+	})
+}
+
+func (c *Client) fetchJson(ctx context.Context, query url.Values, v interface{}, path ...string) error {
+	// create request
+	req, err := http.NewRequestWithContext(ctx, "GET", plexarr.JoinURL(c.url, path...), nil)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
+	}
+
+	req.URL.RawQuery = query.Encode()
+
+	// set headers
+	req.Header.Set("X-Api-Key", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	// send request
+	res, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, plexarr.ErrPvrUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	// validate response
+	if res.StatusCode != 200 {
+		return fmt.Errorf("invalid response: %v: %w", res.StatusCode,
+			plexarr.StatusError(res.StatusCode, plexarr.ErrPvrUnavailable))
+	}
+
+	// decode response
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed decoding response: %w", err)
+	}
+
+	return nil
+}
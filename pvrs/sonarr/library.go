@@ -1,6 +1,7 @@
 package sonarr
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/l3uddz/plexarr"
@@ -8,7 +9,9 @@ import (
 )
 
 type seriesItem struct {
+	Id         uint64  `json:"id"`
 	Title      string  `json:"title"`
+	Year       int     `json:"year"`
 	Path       string  `json:"path"`
 	TvdbId     *uint64 `json:"tvdbId"`
 	Statistics struct {
@@ -17,39 +20,22 @@ type seriesItem struct {
 	Status string `json:"status"`
 }
 
-func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
-	// create request
-	req, err := http.NewRequest("GET", plexarr.JoinURL(c.url, "api", "v3", "series"), nil)
+func (c *Client) GetLibraryItems(ctx context.Context) (*plexarr.PvrLibrary, error) {
+	// retrieve items
+	var sonarrItems []seriesItem
+	err := c.retry.DoRead(ctx, c.log, func() error {
+		items, err := c.getSeries(ctx)
+		sonarrItems = items
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
-	}
-
-	// set headers
-	req.Header.Set("X-Api-Key", c.token)
-	req.Header.Set("Accept", "application/json")
-
-	// send request
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed retrieving sonarr library: %w", err)
-	}
-
-	defer res.Body.Close()
-
-	// validate response
-	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("failed validating sonarr library response: %v", res.StatusCode)
-	}
-
-	// decode response
-	sonarrItems := make([]seriesItem, 0)
-	if err := json.NewDecoder(res.Body).Decode(&sonarrItems); err != nil {
-		return nil, fmt.Errorf("failed decoding sonarr library response: %w", err)
+		return nil, err
 	}
 
 	// create response
 	skipNonUniqueItems := make(map[string]int)
 	pvrItems := make(map[string]plexarr.PvrItem)
+	skippedItems := make([]plexarr.PvrItem, 0)
 	for _, item := range sonarrItems {
 		// skip item if we do not have a file or its marked as deleted
 		if item.Statistics.SizeOnDisk == 0 || item.Status == "deleted" {
@@ -57,12 +43,7 @@ func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
 		}
 
 		// create guids
-		guids := make([]string, 0)
-
-		if item.TvdbId != nil && *item.TvdbId != 0 {
-			guids = append(guids, fmt.Sprintf("com.plexapp.agents.thetvdb://%d", *item.TvdbId))
-		}
-
+		guids := seriesGuids(item)
 		if len(guids) == 0 {
 			c.log.Warn().
 				Interface("series", item).
@@ -72,6 +53,15 @@ func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
 
 		// rewrite path
 		rewritePath := c.rewrite(item.Path)
+		pvrItem := plexarr.PvrItem{
+			Pvr:     c.name,
+			ID:      item.Id,
+			Title:   item.Title,
+			Year:    item.Year,
+			Path:    rewritePath,
+			PvrPath: item.Path,
+			GUID:    guids,
+		}
 
 		// skip this item?
 		if skips, ok := skipNonUniqueItems[rewritePath]; ok {
@@ -84,28 +74,72 @@ func (c *Client) GetLibraryItems() (map[string]plexarr.PvrItem, error) {
 				Msg("Path is not unique, skipping item(s)")
 
 			skipNonUniqueItems[rewritePath]++
+			skippedItems = append(skippedItems, pvrItem)
 			continue
 		}
 
 		// item path exists in map?
-		if _, ok := pvrItems[rewritePath]; ok {
+		if existing, ok := pvrItems[rewritePath]; ok {
 			c.log.Warn().
 				Interface("series", item).
 				Msg("Path is not unique, skipping item(s)")
 
 			skipNonUniqueItems[rewritePath] = 2
+			skippedItems = append(skippedItems, existing, pvrItem)
 			delete(pvrItems, rewritePath)
 			continue
 		}
 
 		// add item
-		pvrItems[rewritePath] = plexarr.PvrItem{
-			Title:   item.Title,
-			Path:    rewritePath,
-			PvrPath: item.Path,
-			GUID:    guids,
-		}
+		pvrItems[rewritePath] = pvrItem
+	}
+
+	return &plexarr.PvrLibrary{
+		Items:   pvrItems,
+		Skipped: skippedItems,
+	}, nil
+}
+
+func (c *Client) getSeries(ctx context.Context) ([]seriesItem, error) {
+	// create request
+	req, err := http.NewRequestWithContext(ctx, "GET", plexarr.JoinURL(c.url, "api", "v3", "series"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, plexarr.ErrFatal)
+	}
+
+	// set headers
+	req.Header.Set("X-Api-Key", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	// send request
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving sonarr library: %v: %w", err, plexarr.ErrPvrUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	// validate response
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed validating sonarr library response: %v: %w", res.StatusCode,
+			plexarr.StatusError(res.StatusCode, plexarr.ErrPvrUnavailable))
+	}
+
+	// decode response
+	sonarrItems := make([]seriesItem, 0)
+	if err := json.NewDecoder(res.Body).Decode(&sonarrItems); err != nil {
+		return nil, fmt.Errorf("failed decoding sonarr library response: %w", err)
+	}
+
+	return sonarrItems, nil
+}
+
+func seriesGuids(item seriesItem) []string {
+	guids := make([]string, 0)
+
+	if item.TvdbId != nil && *item.TvdbId != 0 {
+		guids = append(guids, fmt.Sprintf("com.plexapp.agents.thetvdb://%d", *item.TvdbId))
 	}
 
-	return pvrItems, nil
+	return guids
 }
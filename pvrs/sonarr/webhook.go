@@ -0,0 +1,67 @@
+package sonarr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/l3uddz/plexarr"
+	"io"
+	"path"
+)
+
+type webhookPayload struct {
+	EventType   string     `json:"eventType"`
+	Series      seriesItem `json:"series"`
+	EpisodeFile *struct {
+		Path         string `json:"path"`
+		RelativePath string `json:"relativePath"`
+	} `json:"episodeFile"`
+}
+
+func (c *Client) ParseWebhook(r io.Reader) (*plexarr.WebhookEvent, error) {
+	// decode payload
+	payload := new(webhookPayload)
+	if err := json.NewDecoder(r).Decode(payload); err != nil {
+		return nil, fmt.Errorf("failed decoding sonarr webhook: %w", err)
+	}
+
+	// ignore events that do not change files
+	switch payload.EventType {
+	case "Download", "Rename", "SeriesAdd":
+	default:
+		return nil, nil
+	}
+
+	item := payload.Series
+	if item.Path == "" {
+		return nil, errors.New("sonarr webhook is missing series path")
+	}
+
+	guids := seriesGuids(item)
+	if len(guids) == 0 {
+		return nil, fmt.Errorf("failed creating at-least one plex guid for sonarr webhook: %v", item.Title)
+	}
+
+	// determine imported file
+	file := ""
+	if payload.EpisodeFile != nil {
+		switch {
+		case payload.EpisodeFile.Path != "":
+			file = c.rewrite(payload.EpisodeFile.Path)
+		case payload.EpisodeFile.RelativePath != "":
+			file = c.rewrite(path.Join(item.Path, payload.EpisodeFile.RelativePath))
+		}
+	}
+
+	return &plexarr.WebhookEvent{
+		Type: payload.EventType,
+		Item: plexarr.PvrItem{
+			Pvr:     c.name,
+			Title:   item.Title,
+			Path:    c.rewrite(item.Path),
+			PvrPath: item.Path,
+			GUID:    guids,
+		},
+		File: file,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type csvWriter struct{}
+
+func (csvWriter) Write(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"category", "status", "library", "location", "metadata_item_id", "plex_path",
+		"normalized_path", "plex_guid", "pvr", "title", "pvr_path", "pvr_guids", "new_guid", "error", "plex_episodes",
+		"pvr_episodes", "confidence"}); err != nil {
+		return err
+	}
+
+	for _, c := range Categories {
+		for _, e := range r.Category(c) {
+			metadataId := ""
+			if e.MetadataId != 0 {
+				metadataId = strconv.FormatUint(e.MetadataId, 10)
+			}
+
+			confidence := ""
+			if e.Confidence != 0 {
+				confidence = strconv.FormatFloat(e.Confidence, 'f', 2, 64)
+			}
+
+			if err := cw.Write([]string{string(e.Category), string(e.Status), e.Library, e.Location, metadataId,
+				e.PlexPath, e.NormalizedPath, e.PlexGUID, e.Pvr, e.Title, e.PvrPath, strings.Join(e.PvrGUIDs, " "),
+				e.NewGUID, e.Error, strings.Join(e.PlexEpisodes, " "), strings.Join(e.PvrEpisodes, " "),
+				confidence}); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,22 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type jsonWriter struct{}
+
+func (jsonWriter) Write(w io.Writer, r *Report) error {
+	out := struct {
+		*Report
+		Summary map[Category]int `json:"summary"`
+	}{
+		Report:  r,
+		Summary: r.Counts(),
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
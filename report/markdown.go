@@ -0,0 +1,103 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type markdownWriter struct{}
+
+var categoryTitles = map[Category]string{
+	Matched:       "Validated matches",
+	Mismatched:    "Mismatches",
+	TitleMatched:  "Title pairings (not fixed)",
+	PlexNotFound:  "Plex items without a PVR item",
+	PvrNotFound:   "PVR items without a Plex item",
+	PathCollision: "PVR path collisions (skipped)",
+	Duplicate:     "Split duplicates",
+	Episode:       "Episode mismatches",
+}
+
+func (markdownWriter) Write(w io.Writer, r *Report) error {
+	b := new(strings.Builder)
+
+	fmt.Fprintf(b, "# plexarr %s report\n\n", r.Command)
+	fmt.Fprintf(b, "- Run: `%s`\n", r.Run)
+	fmt.Fprintf(b, "- Started: %s\n", r.Started.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(b, "- Finished: %s\n", r.Finished.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(b, "- Dry run: %v\n", r.DryRun)
+	if len(r.Libraries) > 0 {
+		fmt.Fprintf(b, "- Libraries: %s\n", strings.Join(r.Libraries, ", "))
+	}
+	if len(r.Pvrs) > 0 {
+		fmt.Fprintf(b, "- PVRs: %s\n", strings.Join(r.Pvrs, ", "))
+	}
+
+	// summary
+	counts := r.Counts()
+	b.WriteString("\n## Summary\n\n| Category | Count |\n| --- | --- |\n")
+	for _, c := range Categories {
+		fmt.Fprintf(b, "| %s | %d |\n", categoryTitles[c], counts[c])
+	}
+
+	// summary per location, for libraries spread over multiple locations
+	if locations := r.Locations(); len(locations) > 1 {
+		b.WriteString("\n## Locations\n\n| Location |")
+		for _, c := range Categories {
+			fmt.Fprintf(b, " %s |", categoryTitles[c])
+		}
+		b.WriteString("\n| --- |" + strings.Repeat(" --- |", len(Categories)) + "\n")
+
+		for _, loc := range locations {
+			counts := r.LocationCounts(loc)
+			fmt.Fprintf(b, "| %s |", cell(loc))
+			for _, c := range Categories {
+				fmt.Fprintf(b, " %d |", counts[c])
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	// entries
+	for _, c := range Categories {
+		entries := r.Category(c)
+		if len(entries) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(b, "\n## %s\n\n", categoryTitles[c])
+		if c == Episode {
+			b.WriteString("| Library | Title | Plex path | Plex episodes | PVR path | PVR episodes |\n")
+			b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+			for _, e := range entries {
+				fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s |\n",
+					cell(e.Library), cell(e.Title), cell(e.PlexPath), cell(strings.Join(e.PlexEpisodes, " ")),
+					cell(e.PvrPath), cell(strings.Join(e.PvrEpisodes, " ")))
+			}
+			continue
+		}
+
+		b.WriteString("| Status | Library | Plex path | Plex guid | PVR | PVR path | PVR guids | New guid | Error |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+		for _, e := range entries {
+			fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				cell(string(e.Status)), cell(e.Library), cell(e.PlexPath), cell(e.PlexGUID), cell(e.Pvr),
+				cell(e.PvrPath), cell(strings.Join(e.PvrGUIDs, " ")), cell(e.NewGUID), cell(e.Error))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cell escapes a value for use in a markdown table.
+func cell(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\n", " ")
+	return fmt.Sprintf("`%s`", strings.ReplaceAll(s, "`", "'"))
+}
@@ -0,0 +1,237 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Category string
+
+const (
+	Matched       Category = "matched"
+	Mismatched    Category = "mismatched"
+	TitleMatched  Category = "title_matched"
+	PlexNotFound  Category = "plex_not_found"
+	PvrNotFound   Category = "pvr_not_found"
+	PathCollision Category = "path_collision"
+	Duplicate     Category = "duplicate"
+	Episode       Category = "episode_mismatch"
+)
+
+// Categories lists every category in the order they are reported.
+var Categories = []Category{Matched, Mismatched, TitleMatched, PlexNotFound, PvrNotFound, PathCollision, Duplicate,
+	Episode}
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusFixed   Status = "fixed"
+	StatusSplit   Status = "split"
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+type Entry struct {
+	Category   Category `json:"category"`
+	Status     Status   `json:"status,omitempty"`
+	Library    string   `json:"library,omitempty"`
+	Location   string   `json:"location,omitempty"`
+	MetadataId uint64   `json:"metadata_item_id,omitempty"`
+	PlexPath   string   `json:"plex_path,omitempty"`
+	PlexGUID   string   `json:"plex_guid,omitempty"`
+	Pvr        string   `json:"pvr,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	PvrPath    string   `json:"pvr_path,omitempty"`
+	PvrGUIDs   []string `json:"pvr_guids,omitempty"`
+	NewGUID    string   `json:"new_guid,omitempty"`
+	Error      string   `json:"error,omitempty"`
+
+	// episode mismatches
+	PlexEpisodes []string `json:"plex_episodes,omitempty"`
+	PvrEpisodes  []string `json:"pvr_episodes,omitempty"`
+
+	// path the plex and pvr items were compared by, when normalized
+	NormalizedPath string `json:"normalized_path,omitempty"`
+	// confidence of items matched by title rather than path
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+type Report struct {
+	Run       string    `json:"run"`
+	Command   string    `json:"command"`
+	DryRun    bool      `json:"dry_run"`
+	Libraries []string  `json:"libraries,omitempty"`
+	Pvrs      []string  `json:"pvrs,omitempty"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Entries   []Entry   `json:"entries"`
+
+	mu sync.Mutex
+}
+
+func New(run string, command string, dryRun bool, libraries []string, pvrs []string) *Report {
+	return &Report{
+		Run:       run,
+		Command:   command,
+		DryRun:    dryRun,
+		Libraries: libraries,
+		Pvrs:      pvrs,
+		Started:   time.Now().UTC(),
+		Entries:   make([]Entry, 0),
+	}
+}
+
+// Add records entries, it is safe to call on a nil report.
+func (r *Report) Add(entries ...Entry) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Entries = append(r.Entries, entries...)
+}
+
+// Counts returns the number of entries per category.
+func (r *Report) Counts() map[Category]int {
+	counts := make(map[Category]int)
+	for _, c := range Categories {
+		counts[c] = 0
+	}
+
+	for _, e := range r.Entries {
+		counts[e.Category]++
+	}
+
+	return counts
+}
+
+// Locations returns the library locations of the entries, sorted.
+func (r *Report) Locations() []string {
+	seen := make(map[string]bool)
+	locations := make([]string, 0)
+	for _, e := range r.Entries {
+		if e.Location == "" || seen[e.Location] {
+			continue
+		}
+
+		seen[e.Location] = true
+		locations = append(locations, e.Location)
+	}
+
+	sort.Strings(locations)
+	return locations
+}
+
+// LocationCounts returns the number of entries per category of a library location.
+func (r *Report) LocationCounts(location string) map[Category]int {
+	counts := make(map[Category]int)
+	for _, c := range Categories {
+		counts[c] = 0
+	}
+
+	for _, e := range r.Entries {
+		if e.Location == location {
+			counts[e.Category]++
+		}
+	}
+
+	return counts
+}
+
+// Category returns the entries of a category sorted by path.
+func (r *Report) Category(c Category) []Entry {
+	entries := make([]Entry, 0)
+	for _, e := range r.Entries {
+		if e.Category == c {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entryPath(entries[i]) < entryPath(entries[j])
+	})
+
+	return entries
+}
+
+func entryPath(e Entry) string {
+	if e.PlexPath != "" {
+		return e.PlexPath
+	}
+
+	return e.PvrPath
+}
+
+// Writer writes a report in a specific format.
+type Writer interface {
+	Write(w io.Writer, r *Report) error
+}
+
+var writers = map[string]Writer{
+	"json":     jsonWriter{},
+	"csv":      csvWriter{},
+	"markdown": markdownWriter{},
+}
+
+// Formats returns the supported report formats.
+func Formats() []string {
+	formats := make([]string, 0, len(writers))
+	for f := range writers {
+		formats = append(formats, f)
+	}
+
+	sort.Strings(formats)
+	return formats
+}
+
+// FormatFromPath determines the report format from a file extension.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".md", ".markdown":
+		return "markdown"
+	default:
+		return "json"
+	}
+}
+
+// WriteFile writes the report to path using the format.
+func (r *Report) WriteFile(path string, format string) error {
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+
+	w, ok := writers[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unsupported report format: %v", format)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Finished.IsZero() {
+		r.Finished = time.Now().UTC()
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report: %w", err)
+	}
+
+	if err := w.Write(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("write %v report: %w", format, err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,100 @@
+package plexarr
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	jitter   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterMu sync.Mutex
+)
+
+// RetryConfig configures how often failed requests are retried.
+// Read requests fetch libraries / validate availability, write requests change items (match, split).
+// Set a retry count to -1 to disable retries.
+type RetryConfig struct {
+	Read     int           `yaml:"read"`
+	Write    int           `yaml:"write"`
+	Delay    time.Duration `yaml:"delay"`
+	MaxDelay time.Duration `yaml:"max_delay"`
+}
+
+// Retryable reports whether the request that caused the error may be retried.
+func Retryable(err error) bool {
+	return errors.Is(err, ErrPlexUnavailable) || errors.Is(err, ErrPvrUnavailable)
+}
+
+// StatusError returns the error wrapped for an unexpected response status code.
+// Rate limited and server errors are wrapped with unavailable, so they are retried.
+func StatusError(code int, unavailable error) error {
+	if code == http.StatusTooManyRequests || code >= 500 {
+		return unavailable
+	}
+
+	return ErrFatal
+}
+
+func (c RetryConfig) DoRead(ctx context.Context, l zerolog.Logger, fn func() error) error {
+	retries := c.Read
+	if retries == 0 {
+		retries = 3
+	}
+
+	return c.do(ctx, l, retries, fn)
+}
+
+func (c RetryConfig) DoWrite(ctx context.Context, l zerolog.Logger, fn func() error) error {
+	retries := c.Write
+	if retries == 0 {
+		retries = 2
+	}
+
+	return c.do(ctx, l, retries, fn)
+}
+
+func (c RetryConfig) do(ctx context.Context, l zerolog.Logger, retries int, fn func() error) error {
+	delay, maxDelay := c.Delay, c.MaxDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		// exponential backoff with jitter
+		backoff := delay << uint(attempt)
+		if backoff <= 0 || backoff > maxDelay {
+			backoff = maxDelay
+		}
+		jitterMu.Lock()
+		backoff = backoff/2 + time.Duration(jitter.Int63n(int64(backoff/2)+1))
+		jitterMu.Unlock()
+
+		l.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Dur("backoff", backoff).
+			Msg("Request failed, retrying...")
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package plexarr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// RewriteFirst applies the first matching rule
+	RewriteFirst = "first"
+	// RewriteChain applies every matching rule in turn, each to the result of the previous
+	RewriteChain = "chain"
+)
+
+const (
+	// RewriteRegex replaces matches of a regular expression, to may reference (named) groups
+	RewriteRegex = "regex"
+	// RewriteGlob replaces matches of a glob, * matches within a folder and ** across folders, each is a group
+	RewriteGlob = "glob"
+	// RewritePrefix replaces the from root folder with the to root folder
+	RewritePrefix = "prefix"
+)
+
+// Rewrite maps pvr paths to plex paths with an ordered list of rules.
+// It is configured as a single rule, a list of rules or a mode with a list of rules.
+type Rewrite struct {
+	Mode  string
+	Rules []RewriteRule
+}
+
+type RewriteRule struct {
+	Type string `yaml:"type"`
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+
+	// only rewrite paths starting with prefix
+	Prefix string `yaml:"prefix"`
+
+	// validate the rule rewrites test (to expect) on startup
+	Test   string `yaml:"test"`
+	Expect string `yaml:"expect"`
+}
+
+func (r *Rewrite) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// list of rules
+	rules := make([]RewriteRule, 0)
+	if err := unmarshal(&rules); err == nil {
+		r.Mode, r.Rules = RewriteFirst, rules
+		return nil
+	}
+
+	// single rule, or a mode with rules
+	v := rewriteConfig{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	single := v.From != "" || v.To != "" || v.Prefix != ""
+	if single && len(v.Rules) > 0 {
+		return fmt.Errorf("rewrite accepts either a single rule or rules, not both")
+	}
+
+	r.Mode, r.Rules = v.Mode, v.Rules
+	if single {
+		r.Rules = []RewriteRule{v.RewriteRule}
+	}
+
+	return nil
+}
+
+type rewriteConfig struct {
+	Mode        string        `yaml:"mode"`
+	Rules       []RewriteRule `yaml:"rules"`
+	RewriteRule `yaml:",inline"`
+}
+
+type Rewriter func(string) string
+
+func NewRewriter(r Rewrite) (Rewriter, error) {
+	mode := strings.ToLower(r.Mode)
+	switch mode {
+	case "":
+		mode = RewriteFirst
+	case RewriteFirst, RewriteChain:
+	default:
+		return nil, fmt.Errorf("invalid rewrite mode %q: must be %v or %v", r.Mode, RewriteFirst, RewriteChain)
+	}
+
+	rules := make([]*rewriteRule, 0, len(r.Rules))
+	for i, rule := range r.Rules {
+		// a single rule without from / to rewrites nothing
+		if len(r.Rules) == 1 && (rule.From == "" || rule.To == "") {
+			break
+		}
+
+		compiled, err := compileRewriteRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite rule %d: %w", i+1, err)
+		}
+
+		rules = append(rules, compiled)
+	}
+
+	rewriter := func(input string) string {
+		output := input
+		for _, rule := range rules {
+			rewritten, ok := rule.apply(output)
+			if !ok {
+				continue
+			}
+
+			output = rewritten
+			if mode == RewriteFirst {
+				break
+			}
+		}
+
+		return output
+	}
+
+	return rewriter, nil
+}
+
+type rewriteRule struct {
+	RewriteRule
+	re *regexp.Regexp
+}
+
+func compileRewriteRule(rule RewriteRule) (*rewriteRule, error) {
+	if rule.From == "" || rule.To == "" {
+		return nil, fmt.Errorf("from and to are required")
+	}
+
+	r := &rewriteRule{RewriteRule: rule}
+	switch strings.ToLower(rule.Type) {
+	case "", RewriteRegex:
+		re, err := regexp.Compile(rule.From)
+		if err != nil {
+			return nil, err
+		}
+		r.re = re
+	case RewriteGlob:
+		r.re = globRegexp(rule.From)
+	case RewritePrefix:
+		r.From, r.To = strings.TrimSuffix(rule.From, "/"), strings.TrimSuffix(rule.To, "/")
+	default:
+		return nil, fmt.Errorf("invalid type %q: must be %v, %v or %v", rule.Type, RewriteRegex, RewriteGlob,
+			RewritePrefix)
+	}
+
+	if r.re != nil {
+		if err := validateTemplate(r.re, r.To); err != nil {
+			if rule.Type == "" && strings.Contains(rule.From, "*") {
+				return nil, fmt.Errorf("%w (from is a regular expression, set type: %v for a glob)", err, RewriteGlob)
+			}
+			return nil, err
+		}
+	}
+
+	if rule.Test != "" {
+		output, ok := r.apply(rule.Test)
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("test path %q is not rewritten", rule.Test)
+		case rule.Expect != "" && output != rule.Expect:
+			return nil, fmt.Errorf("test path %q is rewritten to %q, expected %q", rule.Test, output, rule.Expect)
+		}
+	}
+
+	return r, nil
+}
+
+// apply rewrites the input, reporting whether the rule matched.
+func (r *rewriteRule) apply(input string) (string, bool) {
+	if !strings.HasPrefix(input, r.Prefix) {
+		return input, false
+	}
+
+	if r.re == nil {
+		// root folder swap
+		if input != r.From && !strings.HasPrefix(input, r.From+"/") {
+			return input, false
+		}
+
+		return r.To + strings.TrimPrefix(input, r.From), true
+	}
+
+	if !r.re.MatchString(input) {
+		return input, false
+	}
+
+	return r.re.ReplaceAllString(input, r.To), true
+}
+
+// globRegexp translates a glob to an anchored regular expression capturing every * and **.
+func globRegexp(glob string) *regexp.Regexp {
+	b := new(strings.Builder)
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString("(.*)")
+			i++
+		case glob[i] == '*':
+			b.WriteString("([^/]*)")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
+
+// validateTemplate validates every $1, ${1}, $name or ${name} in to references a group of the expression.
+// A common mistake is $1_suffix, which references the group named 1_suffix rather than group 1.
+func validateTemplate(re *regexp.Regexp, to string) error {
+	names := make(map[string]bool)
+	for i, name := range re.SubexpNames() {
+		names[strconv.Itoa(i)] = true
+		if name != "" {
+			names[name] = true
+		}
+	}
+
+	for i := 0; i < len(to); i++ {
+		if to[i] != '$' || i+1 >= len(to) {
+			continue
+		}
+
+		// escaped $
+		if to[i+1] == '$' {
+			i++
+			continue
+		}
+
+		name := ""
+		if to[i+1] == '{' {
+			end := strings.IndexByte(to[i+2:], '}')
+			if end < 0 {
+				continue
+			}
+			name = to[i+2 : i+2+end]
+		} else {
+			end := i + 1
+			for end < len(to) && isTemplateNameChar(to[end]) {
+				end++
+			}
+			name = to[i+1 : end]
+		}
+
+		if name != "" && !names[name] {
+			return fmt.Errorf("to references unknown group %q of %q, use ${n} to separate a group from text",
+				name, re.String())
+		}
+	}
+
+	return nil
+}
+
+func isTemplateNameChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
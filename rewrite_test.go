@@ -0,0 +1,176 @@
+package plexarr
+
+import (
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRewriteUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    Rewrite
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			yaml: `{}`,
+			want: Rewrite{},
+		},
+		{
+			name: "single rule",
+			yaml: `{from: ^/movies/, to: /data/Movies/}`,
+			want: Rewrite{Rules: []RewriteRule{{From: "^/movies/", To: "/data/Movies/"}}},
+		},
+		{
+			name: "single prefix rule",
+			yaml: `{type: prefix, from: /movies, to: /data/Movies, prefix: /movies/}`,
+			want: Rewrite{Rules: []RewriteRule{
+				{Type: RewritePrefix, From: "/movies", To: "/data/Movies", Prefix: "/movies/"},
+			}},
+		},
+		{
+			name: "list of rules",
+			yaml: `[{from: /a, to: /b}, {type: glob, from: /c/**, to: /d/$1}]`,
+			want: Rewrite{Mode: RewriteFirst, Rules: []RewriteRule{
+				{From: "/a", To: "/b"},
+				{Type: RewriteGlob, From: "/c/**", To: "/d/$1"},
+			}},
+		},
+		{
+			name: "mode with rules",
+			yaml: `{mode: chain, rules: [{from: /a, to: /b}, {from: /b, to: /c}]}`,
+			want: Rewrite{Mode: RewriteChain, Rules: []RewriteRule{
+				{From: "/a", To: "/b"},
+				{From: "/b", To: "/c"},
+			}},
+		},
+		{
+			name:    "single rule and rules",
+			yaml:    `{from: /a, to: /b, rules: [{from: /b, to: /c}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			yaml:    `{from: /a, too: /b}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field in list",
+			yaml:    `[{from: /a, too: /b}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rewrite{}
+			decoder := yaml.NewDecoder(strings.NewReader(tt.yaml))
+			decoder.SetStrict(true)
+
+			err := decoder.Decode(&got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		name  string
+		glob  string
+		input string
+		match bool
+		want  []string
+	}{
+		{
+			name:  "double star across folders",
+			glob:  "/mnt/unionfs/Media/**",
+			input: "/mnt/unionfs/Media/Movies/Alien (1979)",
+			match: true,
+			want:  []string{"Movies/Alien (1979)"},
+		},
+		{
+			name:  "single star within folder",
+			glob:  "/mnt/*/Movies",
+			input: "/mnt/disk1/Movies",
+			match: true,
+			want:  []string{"disk1"},
+		},
+		{
+			name:  "single star does not cross folders",
+			glob:  "/mnt/*/Movies",
+			input: "/mnt/disk1/sub/Movies",
+		},
+		{
+			name:  "stars are captured in order",
+			glob:  "/mnt/*/Media/**",
+			input: "/mnt/disk2/Media/TV/Show",
+			match: true,
+			want:  []string{"disk2", "TV/Show"},
+		},
+		{
+			name:  "meta characters are literal",
+			glob:  "/movies (4k)/**",
+			input: "/movies (4k)/Alien (1979)",
+			match: true,
+			want:  []string{"Alien (1979)"},
+		},
+		{
+			name:  "anchored",
+			glob:  "/movies/**",
+			input: "/mnt/movies/Alien (1979)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := globRegexp(tt.glob).FindStringSubmatch(tt.input)
+			if (groups != nil) != tt.match {
+				t.Fatalf("globRegexp(%q) matched %q = %v, want %v", tt.glob, tt.input, groups != nil, tt.match)
+			}
+
+			if tt.match && !reflect.DeepEqual(groups[1:], tt.want) {
+				t.Errorf("globRegexp(%q) groups = %q, want %q", tt.glob, groups[1:], tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{name: "numbered group", from: "^/movies/(.*)$", to: "/data/$1"},
+		{name: "braced numbered group", from: "^/movies/(.*)$", to: "/data/${1}_suffix"},
+		{name: "named group", from: "^/movies/(?P<rest>.*)$", to: "/data/${rest}"},
+		{name: "unbraced named group", from: "^/movies/(?P<rest>.*)$", to: "/data/$rest"},
+		{name: "whole match", from: "^/movies", to: "$0/data"},
+		{name: "escaped dollar", from: "^/movies/(.*)$", to: "/data/$$2/$1"},
+		{name: "trailing dollar", from: "^/movies/(.*)$", to: "/data/$"},
+		{name: "no groups", from: "^/movies/", to: "/data/Movies/"},
+		{name: "unknown numbered group", from: "^/movies/(.*)$", to: "/data/$2", wantErr: true},
+		{name: "group followed by text", from: "^/movies/(.*)$", to: "/data/$1_suffix", wantErr: true},
+		{name: "unknown named group", from: "^/movies/(?P<rest>.*)$", to: "/data/${name}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTemplate(regexp.MustCompile(tt.from), tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTemplate(%q, %q) error = %v, wantErr %v", tt.from, tt.to, err, tt.wantErr)
+			}
+		})
+	}
+}